@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// scope is the access granted to an API key. Each scope includes the
+// ones below it.
+type scope int
+
+const (
+	scopeRead  scope = iota + 1 // reading entries and stats
+	scopeWrite                  // also writing and deleting entries
+	scopeAdmin                  // also flushing and reconfiguring caches
+)
+
+// scopes maps the scope names accepted in configuration to scopes
+var scopes = map[string]scope{
+	"read":  scopeRead,
+	"write": scopeWrite,
+	"admin": scopeAdmin,
+}
+
+// apiKey is an API key in the config file or the API keys file. Scope is
+// "read", "write" or "admin".
+type apiKey struct {
+	Key   string `json:"key"`
+	Scope string `json:"scope"`
+}
+
+// readAPIKeys returns the API keys in the JSON file at path, which holds
+// an object of the form {"api_keys": [{"key", "scope"}]}
+func readAPIKeys(path string) ([]apiKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		APIKeys []apiKey `json:"api_keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return file.APIKeys, nil
+}
+
+// keyScopes returns the scope of each key, rejecting empty keys and
+// unknown scopes
+func keyScopes(keys []apiKey) (map[string]scope, error) {
+	scoped := make(map[string]scope, len(keys))
+	for _, k := range keys {
+		s, known := scopes[k.Scope]
+		switch {
+		case k.Key == "":
+			return nil, errors.New("empty API key")
+		case !known:
+			return nil, fmt.Errorf("unknown API key scope %q", k.Scope)
+		}
+		scoped[k.Key] = s
+	}
+	return scoped, nil
+}
+
+// Authenticator checks the X-API-Key header of requests, or their bearer
+// token if JWT validation is configured, and the verified client
+// certificate of those made over TLS. With no API keys, JWT or client
+// certificate scopes configured, every request is allowed.
+type Authenticator struct {
+	keys    atomic.Pointer[map[string]scope]
+	jwt     atomic.Pointer[jwtVerifier]      // nil if bearer tokens are not accepted
+	clients atomic.Pointer[map[string]scope] // by client certificate common name
+}
+
+// NewAuthenticator returns an Authenticator accepting keys, the bearer
+// tokens described by jwt and the client certificates tls grants scopes to
+func NewAuthenticator(keys []apiKey, jwt jwtSettings, tls tlsSettings) (*Authenticator, error) {
+	auth := &Authenticator{}
+	if err := auth.SetKeys(keys); err != nil {
+		return nil, err
+	}
+	auth.SetJWT(jwt)
+	auth.SetClientScopes(tls)
+	return auth, nil
+}
+
+// SetKeys replaces the accepted keys, leaving them unchanged if any is
+// invalid
+func (auth *Authenticator) SetKeys(keys []apiKey) error {
+	scoped, err := keyScopes(keys)
+	if err != nil {
+		return err
+	}
+	auth.keys.Store(&scoped)
+	return nil
+}
+
+// SetJWT replaces the settings bearer tokens are validated with
+func (auth *Authenticator) SetJWT(jwt jwtSettings) {
+	auth.jwt.Store(newJWTVerifier(jwt))
+}
+
+// SetClientScopes replaces the scopes granted to client certificates with
+// those of tls, which must be valid
+func (auth *Authenticator) SetClientScopes(tls tlsSettings) {
+	clients := tls.clientScopes()
+	auth.clients.Store(&clients)
+}
+
+// scope returns the scope of key, or 0 if it is not accepted. Every key is
+// compared in constant time so that the time taken does not reveal how
+// much of a key was guessed.
+func (auth *Authenticator) scope(key string) scope {
+	var granted scope
+	for k, s := range *auth.keys.Load() {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			granted = s
+		}
+	}
+	return granted
+}
+
+// identity is who a request was authenticated as
+type identity struct {
+	client string // identifies the client for rate limiting
+	scope  scope  // granted to the client, 0 if no credential was accepted
+}
+
+// identityKey is the context key of a request's identity
+type identityKey struct{}
+
+// identify authenticates r by its API key or bearer token, and by its
+// client certificate, whose scope is used if it is the wider. Clients
+// without an accepted credential are identified by their IP address, so
+// that made up credentials do not tell them apart. It returns r with its
+// identity, which later calls reuse rather than authenticating r again.
+func (auth *Authenticator) identify(r *http.Request) (*http.Request, identity) {
+	if id, ok := r.Context().Value(identityKey{}).(identity); ok {
+		return r, id
+	}
+
+	var id identity
+	jwt := auth.jwt.Load()
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found && jwt != nil {
+		var subject string
+		id.scope, subject, _ = jwt.verify(token)
+		id.client = "token " + token
+		if subject != "" {
+			id.client = "sub " + subject
+		}
+	} else if key := r.Header.Get("X-API-Key"); key != "" {
+		id.scope = auth.scope(key)
+		id.client = "key " + key
+	}
+	// Only a certificate verified against the client CAs counts
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if s := (*auth.clients.Load())[cn]; s > id.scope {
+			id.scope = s
+			id.client = "cn " + cn
+		}
+	}
+	if id.scope == 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		id.client = "ip " + host
+	}
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id)), id
+}
+
+// Require wraps handler to respond 401 to requests without an accepted
+// API key, bearer token or client certificate and 403 to those whose
+// credential lacks scope s
+func (auth *Authenticator) Require(s scope, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jwt := auth.jwt.Load()
+		if len(*auth.keys.Load()) == 0 && len(*auth.clients.Load()) == 0 && jwt == nil {
+			handler(w, r)
+			return
+		}
+
+		r, id := auth.identify(r)
+		switch {
+		case id.scope == 0:
+			if jwt != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+		case id.scope < s:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			handler(w, r)
+		}
+	}
+}
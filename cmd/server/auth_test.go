@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withClientCert returns r as if made over TLS with a client certificate
+// for cn, verified against the client CAs if verified is set
+func withClientCert(r *http.Request, cn string, verified bool) *http.Request {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if verified {
+		r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return r
+}
+
+// okHandler responds 200
+func okHandler(w http.ResponseWriter, r *http.Request) {}
+
+func TestRequireClientCertScopes(t *testing.T) {
+	settings := tlsSettings{ClientScopes: map[string]string{"writer": "write", "reader": "read"}}
+	auth, err := NewAuthenticator([]apiKey{{Key: "read-key", Scope: "read"}}, jwtSettings{}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		cn       string
+		verified bool
+		key      string
+		scope    scope
+		status   int
+	}{
+		{"no credential", "", false, "", scopeRead, http.StatusUnauthorized},
+		{"mapped cn", "writer", true, "", scopeWrite, http.StatusOK},
+		{"mapped cn below scope", "reader", true, "", scopeWrite, http.StatusForbidden},
+		{"mapped cn above its scope", "writer", true, "", scopeAdmin, http.StatusForbidden},
+		{"unverified certificate", "writer", false, "", scopeRead, http.StatusUnauthorized},
+		{"unmapped cn", "stranger", true, "", scopeRead, http.StatusUnauthorized},
+		{"cn wider than key", "writer", true, "read-key", scopeWrite, http.StatusOK},
+		{"key without cn", "stranger", true, "read-key", scopeRead, http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.cn != "" {
+			r = withClientCert(r, tt.cn, tt.verified)
+		}
+		if tt.key != "" {
+			r.Header.Set("X-API-Key", tt.key)
+		}
+		w := httptest.NewRecorder()
+		auth.Require(tt.scope, okHandler)(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestClientScopesValidate(t *testing.T) {
+	tests := []struct {
+		settings tlsSettings
+		err      string
+	}{
+		{tlsSettings{Cert: "c", Key: "k", ClientScopes: map[string]string{"svc": "admin"}}, "client_scopes requires client_ca"},
+		{tlsSettings{Cert: "c", Key: "k", ClientCA: "ca", ClientScopes: map[string]string{"svc": "root"}}, `unknown scope "root"`},
+	}
+	for _, tt := range tests {
+		if err := tt.settings.validate(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("validate(%+v) = %v, want an error mentioning %q", tt.settings, err, tt.err)
+		}
+	}
+}
+
+// statusWithKey returns the status handler responds with to a request
+// carrying key, if it is not empty
+func statusWithKey(handler http.HandlerFunc, key string) int {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		r.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+func TestRequireAPIKeys(t *testing.T) {
+	auth, err := NewAuthenticator([]apiKey{{Key: "reader", Scope: "read"}, {Key: "writer", Scope: "write"}}, jwtSettings{}, tlsSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key    string
+		scope  scope
+		status int
+	}{
+		{"", scopeRead, http.StatusUnauthorized},
+		{"unknown", scopeRead, http.StatusUnauthorized},
+		{"reader", scopeRead, http.StatusOK},
+		{"reader", scopeWrite, http.StatusForbidden},
+		{"writer", scopeWrite, http.StatusOK},
+		{"writer", scopeAdmin, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if status := statusWithKey(auth.Require(tt.scope, okHandler), tt.key); status != tt.status {
+			t.Errorf("key %q for scope %d: status %d, want %d", tt.key, tt.scope, status, tt.status)
+		}
+	}
+
+	open, err := NewAuthenticator(nil, jwtSettings{}, tlsSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := statusWithKey(open.Require(scopeAdmin, okHandler), ""); status != http.StatusOK {
+		t.Errorf("status %d without any credentials configured, want 200", status)
+	}
+}
+
+func TestReloadSwapsAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(`{"api_keys": [{"key": "old", "scope": "write"}]}`)
+	auth, err := NewAuthenticator(nil, jwtSettings{}, tlsSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := NewReloader([]string{"lru", "-config", path}, NewRegistry(), auth, NewRateLimiter(rateLimit{}, auth), new(slog.LevelVar))
+	if err := rl.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	handler := auth.Require(scopeWrite, okHandler)
+	if status := statusWithKey(handler, "old"); status != http.StatusOK {
+		t.Fatalf("old key: status %d, want 200", status)
+	}
+
+	writeConfig(`{"api_keys": [{"key": "new", "scope": "write"}]}`)
+	if err := rl.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if status := statusWithKey(handler, "old"); status != http.StatusUnauthorized {
+		t.Errorf("old key after reload: status %d, want 401", status)
+	}
+	if status := statusWithKey(handler, "new"); status != http.StatusOK {
+		t.Errorf("new key after reload: status %d, want 200", status)
+	}
+
+	// An invalid key is rejected without dropping the current ones
+	writeConfig(`{"api_keys": [{"key": "newer", "scope": "root"}]}`)
+	if err := rl.Reload(); err == nil {
+		t.Error("reload accepted an unknown scope")
+	}
+	if status := statusWithKey(handler, "new"); status != http.StatusOK {
+		t.Errorf("new key after a failed reload: status %d, want 200", status)
+	}
+}
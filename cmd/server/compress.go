@@ -0,0 +1,145 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipMinSize is the smallest response body worth compressing; smaller
+// ones are sent as they are
+const gzipMinSize = 1024
+
+// gzipWriters reuses gzip writers, which are costly to allocate
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r allows
+// gzip, by name or through "*", with a non-zero weight
+func acceptsGzip(r *http.Request) bool {
+	accepted := map[string]bool{}
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			var err error
+			if weight, err = strconv.ParseFloat(q, 64); err != nil {
+				weight = 0
+			}
+		}
+		accepted[strings.ToLower(name)] = weight > 0
+	}
+	if ok, found := accepted["gzip"]; found {
+		return ok
+	}
+	return accepted["*"]
+}
+
+// gzipResponseWriter buffers the start of a response until it knows
+// whether the body is large enough to compress
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status int
+	buf    []byte
+	gz     *gzip.Writer // nil until compression starts
+	plain  bool         // whether the body is being sent uncompressed
+}
+
+// WriteHeader delays the status until the body is known to be compressed
+// or not
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.status == 0 {
+		gw.status = status
+	}
+}
+
+// Write buffers b until gzipMinSize bytes have been written, then
+// compresses the body
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	switch {
+	case gw.gz != nil:
+		return gw.gz.Write(b)
+	case gw.plain:
+		return gw.ResponseWriter.Write(b)
+	}
+	if gw.Header().Get("Content-Encoding") != "" {
+		// The handler encoded the body itself
+		gw.sendPlain()
+		return gw.ResponseWriter.Write(b)
+	}
+	gw.buf = append(gw.buf, b...)
+	if len(gw.buf) >= gzipMinSize {
+		gw.startGzip()
+	}
+	return len(b), nil
+}
+
+// writeStatus sends the delayed status
+func (gw *gzipResponseWriter) writeStatus() {
+	if gw.status == 0 {
+		gw.status = http.StatusOK
+	}
+	gw.ResponseWriter.WriteHeader(gw.status)
+}
+
+// startGzip sends the headers for a compressed body and compresses the
+// buffered start of it
+func (gw *gzipResponseWriter) startGzip() {
+	h := gw.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); etag != "" {
+		h.Set("ETag", variantETag(etag, "gzip"))
+	}
+	gw.writeStatus()
+	gw.gz = gzipWriters.Get().(*gzip.Writer)
+	gw.gz.Reset(gw.ResponseWriter)
+	gw.gz.Write(gw.buf)
+	gw.buf = nil
+}
+
+// sendPlain sends the status and the buffered start of an uncompressed
+// body
+func (gw *gzipResponseWriter) sendPlain() {
+	gw.plain = true
+	gw.writeStatus()
+	if len(gw.buf) > 0 {
+		gw.ResponseWriter.Write(gw.buf)
+		gw.buf = nil
+	}
+}
+
+// close finishes the response
+func (gw *gzipResponseWriter) close() {
+	switch {
+	case gw.gz != nil:
+		gw.gz.Close()
+		gzipWriters.Put(gw.gz)
+	case !gw.plain:
+		gw.sendPlain()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// Gzip wraps handler to compress response bodies of at least gzipMinSize
+// bytes with gzip for clients that accept it
+func Gzip(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		handler.ServeHTTP(gw, r)
+	})
+}
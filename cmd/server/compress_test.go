@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipETag(t *testing.T) {
+	body := strings.Repeat("x", gzipMinSize)
+	etag := valueETag([]byte(body))
+	handler := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if notModified(w, r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+
+	tests := []struct {
+		name, acceptEncoding, ifNoneMatch string
+		status                            int
+		etag                              string
+	}{
+		{"identity", "", "", http.StatusOK, etag},
+		{"gzip", "gzip", "", http.StatusOK, variantETag(etag, "gzip")},
+		{"identity revalidated", "", etag, http.StatusNotModified, etag},
+		{"gzip revalidated", "gzip", variantETag(etag, "gzip"), http.StatusNotModified, variantETag(etag, "gzip")},
+		{"weak gzip revalidated", "gzip", "W/" + variantETag(etag, "gzip"), http.StatusNotModified, variantETag(etag, "gzip")},
+		{"gzip tag without gzip", "", variantETag(etag, "gzip"), http.StatusNotModified, variantETag(etag, "gzip")},
+		{"other tag", "gzip", `"other"`, http.StatusOK, variantETag(etag, "gzip")},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.status || w.Header().Get("ETag") != tt.etag {
+			t.Errorf("%s: status %d, ETag %s; want %d, %s", tt.name, w.Code, w.Header().Get("ETag"), tt.status, tt.etag)
+		}
+	}
+	if variantETag(etag, "gzip") == etag {
+		t.Errorf("variantETag(%s, gzip) did not change the tag", etag)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// duration is a time.Duration written as a string such as "10s" in the
+// config file and on the command line
+type duration time.Duration
+
+// String implements flag.Value
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set implements flag.Value
+func (d *duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// UnmarshalJSON accepts a duration string such as "10s"
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10s\": %w", err)
+	}
+	return d.Set(s)
+}
+
+// config is the server configuration. Each setting is taken from, in
+// increasing order of precedence, its default, the config file named
+// by -config, its environment variable if it has one, and its flag.
+// Capacity, TTL and Policy configure the default cache unless Caches or
+// the namespaces file list it.
+type config struct {
+	Addr            string             `json:"addr"`
+	DebugAddr       string             `json:"debug_addr"`
+	Capacity        int                `json:"capacity"`
+	TTL             int                `json:"ttl"`
+	Policy          string             `json:"policy"`
+	Origin          string             `json:"origin"`
+	Namespaces      string             `json:"namespaces"`
+	Caches          []namespaceRequest `json:"caches"`
+	Snapshot        string             `json:"snapshot"`
+	ShutdownTimeout duration           `json:"shutdown_timeout"`
+	ReadTimeout     duration           `json:"read_timeout"`
+	WriteTimeout    duration           `json:"write_timeout"`
+	IdleTimeout     duration           `json:"idle_timeout"`
+	MaxHeaderBytes  int                `json:"max_header_bytes"`
+	MaxBodyBytes    int64              `json:"max_body_bytes"`
+	TLS             tlsSettings        `json:"tls"`
+	APIKeys         []apiKey           `json:"api_keys"`
+	APIKeysFile     string             `json:"api_keys_file"`
+	JWT             jwtSettings        `json:"jwt"`
+	RateLimit       rateLimit          `json:"rate_limit"`
+	Log             logSettings        `json:"log"`
+	CORS            corsSettings       `json:"cors"`
+	Tracing         tracingSettings    `json:"tracing"`
+
+	path string // of the config file, if any
+}
+
+// defaultConfig returns the configuration used when nothing is set
+func defaultConfig() config {
+	return config{
+		Addr:            ":8080",
+		Capacity:        1024,
+		TTL:             50000,
+		ShutdownTimeout: duration(10 * time.Second),
+		ReadTimeout:     duration(30 * time.Second),
+		WriteTimeout:    duration(30 * time.Second),
+		IdleTimeout:     duration(2 * time.Minute),
+		MaxHeaderBytes:  1 << 20,
+		MaxBodyBytes:    10 << 20,
+	}
+}
+
+// bind defines the flags setting cfg's fields on fs
+func (cfg *config) bind(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "address to listen on (env LRU_ADDR)")
+	fs.StringVar(&cfg.DebugAddr, "debug-addr", cfg.DebugAddr, "address to serve pprof and expvar on, none if empty; keep it private")
+	fs.IntVar(&cfg.Capacity, "capacity", cfg.Capacity, "capacity of the default cache (env LRU_CAPACITY)")
+	fs.IntVar(&cfg.TTL, "ttl", cfg.TTL, "expiration time in seconds of the default cache, 0 for never (env LRU_TTL)")
+	fs.StringVar(&cfg.Policy, "policy", cfg.Policy, "eviction policy of the default cache")
+	fs.StringVar(&cfg.Origin, "origin", cfg.Origin, "base URL to read missing keys through from, as GET {origin}/{key}")
+	fs.StringVar(&cfg.Namespaces, "namespaces", cfg.Namespaces, "path to a JSON file listing the named caches to create")
+	fs.StringVar(&cfg.Snapshot, "snapshot", cfg.Snapshot, "path of a file to restore the caches from at startup and save them to on shutdown")
+	fs.StringVar(&cfg.TLS.Cert, "tls-cert", cfg.TLS.Cert, "path to a PEM certificate to serve HTTPS with")
+	fs.StringVar(&cfg.TLS.Key, "tls-key", cfg.TLS.Key, "path to the PEM private key of -tls-cert")
+	fs.StringVar(&cfg.TLS.MinVersion, "tls-min-version", cfg.TLS.MinVersion, "minimum TLS version, 1.2 or 1.3")
+	fs.StringVar(&cfg.TLS.ClientCA, "tls-client-ca", cfg.TLS.ClientCA, "path to PEM CA certificates that client certificates must be signed by")
+	fs.StringVar(&cfg.APIKeysFile, "api-keys-file", cfg.APIKeysFile, "path to a JSON file listing the API keys requests must present")
+	fs.StringVar(&cfg.JWT.JWKSURL, "jwt-jwks-url", cfg.JWT.JWKSURL, "URL of the JWKS document bearer tokens are verified with")
+	fs.Float64Var(&cfg.RateLimit.RPS, "rate-limit-rps", cfg.RateLimit.RPS, "requests per second allowed to each client, 0 for no limit")
+	fs.IntVar(&cfg.RateLimit.Burst, "rate-limit-burst", cfg.RateLimit.Burst, "requests each client may make at once, by default -rate-limit-rps rounded up")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "minimum level logged: debug, info, warn or error")
+	fs.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log output format: text or json")
+	fs.StringVar(&cfg.Tracing.Endpoint, "otlp-endpoint", cfg.Tracing.Endpoint, "base URL of the OTLP/HTTP collector to export traces to, none if empty (env OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.Var(&cfg.ShutdownTimeout, "shutdown-timeout", "how long to wait for in-flight requests on shutdown, as a `duration` such as 10s")
+	fs.Var(&cfg.ReadTimeout, "read-timeout", "longest time to read a request, headers and body, as a `duration`")
+	fs.Var(&cfg.WriteTimeout, "write-timeout", "longest time from the end of the request headers to the end of the response, as a `duration`")
+	fs.Var(&cfg.IdleTimeout, "idle-timeout", "how long an idle keep-alive connection is kept open, as a `duration`")
+	fs.IntVar(&cfg.MaxHeaderBytes, "max-header-bytes", cfg.MaxHeaderBytes, "largest size of the request headers")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", cfg.MaxBodyBytes, "largest size of a request body")
+}
+
+// load reads the config file at path into cfg. The file is TOML if its
+// name ends in .toml and JSON otherwise, with the same keys either way.
+// Settings the file leaves out keep their current values.
+func (cfg *config) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		// A TOML file is converted to JSON so that both are decoded by the
+		// same rules
+		doc, err := parseTOML(string(data))
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		data, _ = json.Marshal(doc) // parseTOML returns only JSON values
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides cfg with the environment variables that are set
+func (cfg *config) loadEnv() error {
+	if s, ok := os.LookupEnv("LRU_ADDR"); ok {
+		cfg.Addr = s
+	}
+	if s, ok := os.LookupEnv("LRU_JWT_SECRET"); ok {
+		cfg.JWT.Secret = s
+	}
+	if s, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT"); ok {
+		cfg.Tracing.Endpoint = s
+	}
+	if s, ok := os.LookupEnv("OTEL_SERVICE_NAME"); ok {
+		cfg.Tracing.ServiceName = s
+	}
+	for name, field := range map[string]*int{"LRU_CAPACITY": &cfg.Capacity, "LRU_TTL": &cfg.TTL} {
+		s, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", name, s)
+		}
+		*field = n
+	}
+	return nil
+}
+
+// validate checks the settings that are not validated where they are used
+func (cfg config) validate() error {
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return fmt.Errorf("invalid address %q: %w", cfg.Addr, err)
+	}
+	if _, _, err := net.SplitHostPort(cfg.DebugAddr); cfg.DebugAddr != "" && err != nil {
+		return fmt.Errorf("invalid debug address %q: %w", cfg.DebugAddr, err)
+	}
+	if cfg.Capacity < 1 {
+		return fmt.Errorf("capacity %d less than 1", cfg.Capacity)
+	}
+	if cfg.TTL < 0 {
+		return fmt.Errorf("ttl %d is negative", cfg.TTL)
+	}
+	if _, err := keyScopes(cfg.APIKeys); err != nil {
+		return err
+	}
+	if err := cfg.JWT.validate(); err != nil {
+		return err
+	}
+	if err := cfg.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Log.validate(); err != nil {
+		return err
+	}
+	if err := cfg.CORS.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Tracing.validate(); err != nil {
+		return err
+	}
+	if _, known := policies[cfg.Policy]; cfg.Policy != "" && !known {
+		return fmt.Errorf("unknown policy %q", cfg.Policy)
+	}
+	for name, timeout := range map[string]duration{
+		"shutdown": cfg.ShutdownTimeout, "read": cfg.ReadTimeout, "write": cfg.WriteTimeout, "idle": cfg.IdleTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("%s timeout %v is negative", name, timeout)
+		}
+	}
+	if cfg.MaxHeaderBytes < 1 || cfg.MaxBodyBytes < 1 {
+		return fmt.Errorf("max header bytes %d and max body bytes %d must be positive", cfg.MaxHeaderBytes, cfg.MaxBodyBytes)
+	}
+	return cfg.TLS.validate()
+}
+
+// cacheRequests returns the caches configured by cfg: those listed in
+// Caches and in the namespaces file, followed by the default cache unless
+// either lists it
+func (cfg config) cacheRequests() ([]namespaceRequest, error) {
+	if err := validateAll(cfg.path, cfg.Caches); err != nil {
+		return nil, err
+	}
+	reqs := slices.Clone(cfg.Caches)
+	if cfg.Namespaces != "" {
+		more, err := readNamespaces(cfg.Namespaces)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, more...)
+	}
+
+	names := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		if names[req.Name] {
+			return nil, fmt.Errorf("cache %q configured more than once", req.Name)
+		}
+		names[req.Name] = true
+	}
+	if !names[defaultNamespace] {
+		reqs = append(reqs, namespaceRequest{Name: defaultNamespace, Capacity: cfg.Capacity, TTL: cfg.TTL, Policy: cfg.Policy})
+	}
+	return reqs, nil
+}
+
+// apiKeys returns the API keys listed in APIKeys and in the API keys file
+func (cfg config) apiKeys() ([]apiKey, error) {
+	keys := slices.Clone(cfg.APIKeys)
+	if cfg.APIKeysFile != "" {
+		more, err := readAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, more...)
+	}
+	return keys, nil
+}
+
+// parseConfig builds the configuration from the command-line arguments,
+// the config file they name and the environment
+func parseConfig(args []string) (config, error) {
+	// The flags are parsed first to find the config file, then replayed
+	// over the file and the environment so that they take precedence
+	parsed := defaultConfig()
+	fs := flag.NewFlagSet(args[0], flag.ExitOnError)
+	parsed.bind(fs)
+	path := fs.String("config", "", "path to a JSON config file, or a TOML one if it ends in .toml")
+	fs.Parse(args[1:])
+
+	cfg := defaultConfig()
+	if *path != "" {
+		if err := cfg.load(*path); err != nil {
+			return config{}, err
+		}
+		cfg.path = *path
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return config{}, err
+	}
+	replay := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cfg.bind(replay)
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && err == nil {
+			err = replay.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return config{}, err
+	}
+	return cfg, cfg.validate()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// corsSettings configures cross-origin requests from browsers. CORS is
+// disabled unless AllowedOrigins lists origins such as
+// "https://admin.example.com", or "*" for any. AllowedMethods and
+// AllowedHeaders default to the methods and request headers the API uses.
+// MaxAge is how many seconds browsers may cache a preflight response.
+type corsSettings struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers"`
+	MaxAge         int      `json:"max_age"`
+}
+
+// validate checks that MaxAge is not negative
+func (s corsSettings) validate() error {
+	if s.MaxAge < 0 {
+		return errors.New("cors: negative max_age")
+	}
+	return nil
+}
+
+// allowsOrigin reports whether requests from origin are allowed
+func (s corsSettings) allowsOrigin(origin string) bool {
+	return slices.Contains(s.AllowedOrigins, "*") || slices.Contains(s.AllowedOrigins, origin)
+}
+
+// CORS wraps handler to answer preflight requests and add CORS headers to
+// the responses to allowed origins. Preflight requests from other origins
+// are rejected with 403; their other requests are served without CORS
+// headers, so browsers withhold the responses.
+func CORS(s corsSettings, handler http.Handler) http.Handler {
+	if len(s.AllowedOrigins) == 0 {
+		return handler
+	}
+	methods := s.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPost, http.MethodDelete}
+	}
+	headers := s.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "Authorization", "X-API-Key", "If-None-Match"}
+	}
+	allowMethods, allowHeaders := strings.Join(methods, ", "), strings.Join(headers, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !s.allowsOrigin(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", "Deprecation, ETag, Link, Retry-After, WWW-Authenticate")
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		if s.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(s.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
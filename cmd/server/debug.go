@@ -0,0 +1,45 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/NithinkumarHV/LRU/lru"
+)
+
+// DebugHandler returns the handler of the debug listener, serving the
+// pprof profiles under /debug/pprof/ and the expvar variables, memory
+// statistics among them, at /debug/vars
+func DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	return mux
+}
+
+// cacheVars is the expvar view of a cache
+type cacheVars struct {
+	lru.Stats
+	Len      int   `json:"len"`
+	Capacity int   `json:"capacity"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// publishCaches exports the counters of reg's caches, by name, as the
+// expvar variable "caches"
+func publishCaches(reg *Registry) {
+	expvar.Publish("caches", expvar.Func(func() any {
+		vars := map[string]cacheVars{}
+		for _, name := range reg.Names() {
+			if cache, found := reg.Get(name); found {
+				vars[name] = cacheVars{cache.Stats(), cache.Len(), cache.Cap(), cache.Bytes()}
+			}
+		}
+		return vars
+	}))
+}
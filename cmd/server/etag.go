@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// valueETag returns the strong entity tag of the response serving value,
+// derived from a hash of the value
+func valueETag(value []byte) string {
+	sum := sha256.Sum256(value)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagSuffixes maps the encodings middlewares re-encode response bodies
+// with, media types and content codings, to the suffix each adds to the
+// entity tag of the body. A strong tag promises byte for byte equality, so
+// every encoding needs its own.
+var etagSuffixes = map[string]string{
+	msgpackType:  "-msgpack",
+	protobufType: "-protobuf",
+	"gzip":       "-gzip",
+}
+
+// variantETag returns the entity tag of the representation made by
+// re-encoding the one tagged etag with coding, a key of etagSuffixes
+func variantETag(etag, coding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return etag[:len(etag)-1] + etagSuffixes[coding] + `"`
+}
+
+// baseETag returns the entity tag that tag was derived from by
+// variantETag, without the weak prefix
+func baseETag(tag string) string {
+	tag = strings.TrimPrefix(tag, "W/")
+	for stripped := true; stripped; {
+		stripped = false
+		for _, suffix := range etagSuffixes {
+			if rest, found := strings.CutSuffix(tag, suffix+`"`); found {
+				tag, stripped = rest+`"`, true
+			}
+		}
+	}
+	return tag
+}
+
+// notModified reports whether the If-None-Match header of r matches etag
+// or a variant of it, comparing weakly as RFC 9110 requires. If so, it
+// sets the ETag header of w to the matching tag, since the 304 response
+// must carry the tag of the representation the client holds.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "*":
+			return true
+		case baseETag(tag) == etag:
+			w.Header().Set("ETag", strings.TrimPrefix(tag, "W/"))
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NithinkumarHV/LRU/lru"
+)
+
+// Cache is the cache type served over HTTP. Values are arbitrary JSON
+// documents, stored as received and echoed back verbatim.
+type Cache = lru.LRUCache[string, json.RawMessage]
+
+// SetRequest is the JSON body accepted by the set endpoints. lru.CacheItem
+// keeps its fields unexported, so it cannot be decoded into directly.
+// TTL is in seconds; zero means the cache's default expiration. Negative
+// caches the key as known to be missing, in which case Value is ignored
+// and TTL is required. Priority is one of "low", "normal" or "high". Mode
+// makes the write conditional: "nx" only sets a missing key and "xx" only
+// sets an existing one. ExpireAt is an RFC 3339 deadline used instead of
+// TTL for plain writes.
+type SetRequest struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	TTL      int             `json:"ttl,omitempty"`
+	ExpireAt *time.Time      `json:"expire_at,omitempty"`
+	Negative bool            `json:"negative,omitempty"`
+	Priority string          `json:"priority,omitempty"`
+	Mode     string          `json:"mode,omitempty"`
+}
+
+// priorities maps the names accepted in SetRequest.Priority to tiers
+var priorities = map[string]lru.Priority{
+	"low":    lru.PriorityLow,
+	"normal": lru.PriorityNormal,
+	"high":   lru.PriorityHigh,
+}
+
+// errMissingKey is returned when a request does not name a key
+var errMissingKey = errors.New("missing key")
+
+// validate returns why req is not a valid write, or nil if it is
+func (req SetRequest) validate() error {
+	_, known := priorities[req.Priority]
+	switch {
+	case req.Key == "":
+		return errMissingKey
+	case req.TTL < 0:
+		return errors.New("ttl must not be negative")
+	case req.Negative && req.TTL == 0:
+		return errors.New("negative entries need a ttl")
+	case !req.Negative && len(req.Value) == 0:
+		return errors.New("missing value")
+	case req.Priority != "" && !known:
+		return fmt.Errorf("unknown priority %q", req.Priority)
+	case req.Mode != "" && req.Negative:
+		return errors.New("mode does not apply to negative entries")
+	case req.Mode != "" && req.Mode != "nx" && req.Mode != "xx":
+		return fmt.Errorf("unknown mode %q", req.Mode)
+	case req.ExpireAt != nil && (req.TTL != 0 || req.Negative || req.Mode != ""):
+		return errors.New("expire_at cannot be combined with ttl, negative or mode")
+	case req.ExpireAt != nil && !req.ExpireAt.After(time.Now()):
+		return errors.New("expire_at is in the past")
+	}
+	return nil
+}
+
+// queryKey returns the key query parameter of r
+func queryKey(r *http.Request) (string, error) {
+	keys, ok := r.URL.Query()["key"]
+	if !ok || len(keys[0]) < 1 {
+		return "", errMissingKey
+	}
+	return keys[0], nil
+}
+
+// pathKey returns the {key} path segment of r
+func pathKey(r *http.Request) (string, error) {
+	key := r.PathValue("key")
+	if key == "" {
+		return "", errMissingKey
+	}
+	return key, nil
+}
+
+// GetHandler handles GET requests to retrieve the value cached under the
+// {key} path segment. A missing key yields 404 so that any value,
+// including -1, can be cached. Keys cached as known to be missing also
+// yield 404, with a body of {"negative": true}. When the server has an
+// origin, misses are read through from it instead, and an origin failure
+// yields 502. Values are served with an ETag, and a request whose
+// If-None-Match matches it gets 304 without the value.
+func GetHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := pathKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		value, result, err := lookup(r.Context(), cache, key)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		switch result {
+		case lru.Miss:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case lru.NegativeHit:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]bool{"negative": true})
+			return
+		}
+		etag := valueETag(value)
+		w.Header().Set("ETag", etag)
+		if notModified(w, r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		response := map[string]json.RawMessage{"value": value}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// lookupResults names lookup results in traces
+var lookupResults = map[lru.LookupResult]string{lru.Hit: "hit", lru.Miss: "miss", lru.NegativeHit: "negative_hit"}
+
+// lookup returns the value of key as GetHandler serves it, reading misses
+// through from the origin if there is one, in a span of the trace of ctx.
+// The error is the origin's.
+func lookup(ctx context.Context, cache *Cache, key string) (_ json.RawMessage, result lru.LookupResult, err error) {
+	ctx, sp := startSpan(ctx, "cache lookup", spanInternal)
+	sp.set("cache.key", key)
+	defer func() {
+		sp.set("cache.result", lookupResults[result])
+		if err != nil {
+			sp.fail(err)
+		}
+		sp.finish()
+	}()
+
+	if origin == "" {
+		value, result := cache.Lookup(key)
+		return value, result, nil
+	}
+	value, err := cache.GetOrLoad(key, func(key string) (json.RawMessage, error) {
+		// The load is shared by every request missing on key, so it must
+		// not be canceled with the one that started it. It stays in that
+		// request's trace and is bounded by originClient's timeout.
+		return fetch(context.WithoutCancel(ctx), key)
+	})
+	switch {
+	case errors.Is(err, lru.ErrNegativeEntry):
+		return nil, lru.NegativeHit, nil
+	case errors.Is(err, errOriginMiss):
+		return nil, lru.Miss, nil
+	case err != nil:
+		return nil, lru.Miss, err
+	}
+	return value, lru.Hit, nil
+}
+
+// SetHandler handles PUT requests that store a value under the {key} path
+// segment, given a SetRequest body whose key may be omitted. It responds
+// 201 if the key was not cached before and 204 if its value was replaced.
+func SetHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := pathKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var item SetRequest
+		err = json.NewDecoder(r.Body).Decode(&item)
+		if err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if item.Key != "" && item.Key != key {
+			http.Error(w, "key in body does not match the path", http.StatusBadRequest)
+			return
+		}
+		item.Key = key
+		if err := item.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(store(cache, item))
+	}
+}
+
+// store performs the validated write req and returns the status SetHandler
+// responds with: 201 if the key was not cached before, 204 if its value
+// was replaced and 412 if the write's mode prevented it.
+func store(cache *Cache, req SetRequest) int {
+	opts := lru.SetOptions{
+		TTL:       time.Duration(req.TTL) * time.Second,
+		Negative:  req.Negative,
+		IfAbsent:  req.Mode == "nx",
+		IfPresent: req.Mode == "xx",
+	}
+	if req.ExpireAt != nil {
+		opts.Deadline = *req.ExpireAt
+	}
+	if req.Priority != "" {
+		priority := priorities[req.Priority]
+		opts.Priority = &priority
+	}
+	stored, replaced := cache.SetWithOptions(req.Key, req.Value, opts)
+	switch {
+	case !stored:
+		return http.StatusPreconditionFailed
+	case replaced:
+		return http.StatusNoContent
+	}
+	return http.StatusCreated
+}
+
+// DeleteHandler handles DELETE requests to remove a key from the cache
+func DeleteHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := pathKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !cache.Delete(key) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HeadHandler handles HEAD requests that check whether a key is cached,
+// responding with the ETag GetHandler would and 304 if If-None-Match
+// matches it
+func HeadHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := pathKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		value, found := cache.Peek(key)
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		etag := valueETag(value)
+		w.Header().Set("ETag", etag)
+		if notModified(w, r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// FlushHandler handles POST requests that empty the cache
+func FlushHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cache.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MGetHandler handles GET requests to retrieve several keys at once,
+// given as repeated key query parameters
+func MGetHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()["key"]
+		if len(params) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		for _, key := range params {
+			if key == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		response := map[string]map[string]json.RawMessage{"values": cache.MGet(params)}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// MSetHandler handles POST requests to set several keys at once
+func MSetHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var items []SetRequest
+		err := json.NewDecoder(r.Body).Decode(&items)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		batch := make(map[string]json.RawMessage, len(items))
+		for _, item := range items {
+			if item.Key == "" || len(item.Value) == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			batch[item.Key] = item.Value
+		}
+		cache.MSet(batch)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// TTLHandler handles GET requests for the remaining lifetime of a key,
+// reported in seconds, or -1 if the key never expires
+func TTLHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := queryKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ttl, found := cache.TTL(key)
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		seconds := -1
+		if ttl != lru.NoExpiration {
+			seconds = int(ttl.Seconds())
+		}
+		response := map[string]int{"ttl": seconds}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// TouchHandler handles POST requests that reset a key's expiration to the
+// cache default
+func TouchHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := queryKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !cache.Touch(key) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ExpireHandler handles POST requests that set a new ttl, in seconds, on a
+// key
+func ExpireHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := queryKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ttl, err := strconv.Atoi(r.URL.Query().Get("ttl"))
+		if err != nil || ttl <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !cache.Expire(key, time.Duration(ttl)*time.Second) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PersistHandler handles POST requests that remove a key's expiration
+func PersistHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := queryKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !cache.Persist(key) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// infoResponse is the JSON body returned by InfoHandler
+type infoResponse struct {
+	Value       json.RawMessage `json:"value"`
+	ExpireAt    *time.Time      `json:"expire_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	AccessCount uint64          `json:"access_count"`
+}
+
+// InfoHandler handles GET requests to retrieve a value together with its
+// metadata
+func InfoHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := queryKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		info, found := cache.GetWithInfo(key)
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response := infoResponse{
+			Value:       info.Value,
+			CreatedAt:   info.CreatedAt,
+			AccessCount: info.AccessCount,
+		}
+		if !info.ExpireAt.IsZero() {
+			response.ExpireAt = &info.ExpireAt
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ScanHandler handles GET requests that page through the cache keys. The
+// cursor and count query parameters are optional; a returned cursor of 0
+// means the scan is complete.
+func ScanHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cursor, count, err := scanParams(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		keys, next := cache.Scan(cursor, count)
+		response := struct {
+			Cursor uint64   `json:"cursor"`
+			Keys   []string `json:"keys"`
+		}{next, keys}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// scanParams returns the optional cursor and count query parameters of r
+func scanParams(r *http.Request) (cursor uint64, count int, err error) {
+	query := r.URL.Query()
+	if c := query.Get("cursor"); c != "" {
+		cursor, err = strconv.ParseUint(c, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if c := query.Get("count"); c != "" {
+		count, err = strconv.Atoi(c)
+		if err != nil {
+			return 0, 0, err
+		}
+		if count < 0 {
+			return 0, 0, errors.New("negative count")
+		}
+	}
+	return cursor, count, nil
+}
+
+// keyInfo describes a key in KeysHandler responses. TTL is in seconds, or
+// -1 if the key never expires, and Size is the length of the key and its
+// value in bytes.
+type keyInfo struct {
+	Key  string `json:"key"`
+	TTL  int    `json:"ttl"`
+	Size int    `json:"size"`
+}
+
+// KeysHandler handles GET requests that page through the cached keys with
+// their remaining TTL and approximate size. The cursor and count query
+// parameters work as for ScanHandler, and the optional pattern parameter
+// restricts the keys to those matching a glob. With a pattern, count
+// bounds the keys examined, so a page may be short or empty before the
+// returned cursor reaches 0.
+func KeysHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cursor, count, err := scanParams(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var entries []lru.Entry[string, json.RawMessage]
+		if pattern := r.URL.Query().Get("pattern"); pattern != "" {
+			entries, cursor = lru.ScanMatching(cache, cursor, count, pattern)
+		} else {
+			entries, cursor = cache.ScanEntries(cursor, count)
+		}
+
+		keys := make([]keyInfo, len(entries))
+		for i, entry := range entries {
+			ttl := -1
+			if !entry.ExpireAt.IsZero() {
+				ttl = int(time.Until(entry.ExpireAt).Seconds())
+			}
+			keys[i] = keyInfo{entry.Key, ttl, len(entry.Key) + len(entry.Value)}
+		}
+		response := struct {
+			Cursor uint64    `json:"cursor"`
+			Keys   []keyInfo `json:"keys"`
+		}{cursor, keys}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetDelHandler handles POST requests that retrieve a value and remove it
+// from the cache in one step
+func GetDelHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := queryKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		value, found := cache.GetDel(key)
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response := map[string]json.RawMessage{"value": value}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CapacityHandler handles PUT requests that resize the cache, given a body
+// of the form {"capacity": n}
+func CapacityHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Capacity int `json:"capacity"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Capacity <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		evicted := cache.Resize(req.Capacity)
+		response := map[string]int{"capacity": req.Capacity, "evicted": evicted}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// DefaultTTLHandler handles PUT requests that change the cache's default
+// expiration time, given a body of the form {"ttl": seconds}
+func DefaultTTLHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TTL int `json:"ttl"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.TTL <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		cache.SetDefaultTTL(time.Duration(req.TTL) * time.Second)
+		response := map[string]int{"ttl": req.TTL}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// StatsHandler handles GET requests for the cache's counters
+func StatsHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := cache.Stats()
+		response := struct {
+			lru.Stats
+			HitRate  float64      `json:"hit_rate"`
+			HitRates lru.HitRates `json:"hit_rates"`
+			Len      int          `json:"len"`
+			Capacity int          `json:"capacity"`
+			Bytes    int64        `json:"bytes"`
+			MaxBytes int64        `json:"max_bytes,omitempty"`
+		}{stats, stats.HitRate(), cache.HitRates(), cache.Len(), cache.Cap(), cache.Bytes(), cache.MaxBytes()}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// HotKeysHandler handles GET requests for the cache's most read keys,
+// hottest first, with estimates of their recent reads
+func HotKeysHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys := cache.HotKeys()
+		if keys == nil {
+			keys = []lru.HotKey[string]{}
+		}
+		response := map[string][]lru.HotKey[string]{"hot_keys": keys}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ResetStatsHandler handles POST requests that zero the cache's counters
+func ResetStatsHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cache.ResetStats()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
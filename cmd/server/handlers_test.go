@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/NithinkumarHV/LRU/lru"
+)
+
+func TestStoreStatus(t *testing.T) {
+	cache := lru.NewLRUCache[string, json.RawMessage](10, 0)
+	tests := []struct {
+		mode   string
+		status int
+	}{
+		{"xx", http.StatusPreconditionFailed},
+		{"", http.StatusCreated},
+		{"", http.StatusNoContent},
+		{"nx", http.StatusPreconditionFailed},
+		{"xx", http.StatusNoContent},
+	}
+	for i, tt := range tests {
+		if status := store(cache, SetRequest{Key: "k", Value: json.RawMessage("1"), Mode: tt.mode}); status != tt.status {
+			t.Errorf("write %d with mode %q: status %d, want %d", i, tt.mode, status, tt.status)
+		}
+	}
+}
+
+func TestStoreCreatesOnce(t *testing.T) {
+	cache := lru.NewLRUCache[string, json.RawMessage](1000, 0)
+	for round := range 200 {
+		key := strconv.Itoa(round)
+		start := make(chan struct{})
+		statuses := make(chan int, 8)
+		for range cap(statuses) {
+			go func() {
+				<-start
+				statuses <- store(cache, SetRequest{Key: key, Value: json.RawMessage("1")})
+			}()
+		}
+		close(start)
+		created := 0
+		for range cap(statuses) {
+			if <-statuses == http.StatusCreated {
+				created++
+			}
+		}
+		if created != 1 {
+			t.Fatalf("%d of %d concurrent writes of a new key answered 201", created, cap(statuses))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// HealthzHandler handles liveness probes. It responds 200 whenever the
+// process is serving requests.
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// ReadyzHandler handles readiness probes. It responds 503 while ready is
+// unset, before the configured caches are created and restored and once
+// shutdown begins, and while the cleanup goroutine of any cache has
+// stalled; otherwise it responds 200.
+// The body names the caches whose cleanup has stalled.
+func ReadyzHandler(reg *Registry, ready *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "starting"})
+			return
+		}
+
+		stalled := []string{}
+		for _, name := range reg.Names() {
+			if cache, found := reg.Get(name); found && !cache.CleanupAlive() {
+				stalled = append(stalled, name)
+			}
+		}
+		if len(stalled) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]any{"status": "cleanup stalled", "caches": stalled})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+	}
+}
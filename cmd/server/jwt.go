@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtSettings configures the validation of JWT bearer tokens. Tokens are
+// signed either with HS256 using Secret or with RS256 or ES256 using a
+// key from the JWKS document at JWKSURL. If set, Issuer and Audience must
+// match the token's iss and aud claims. LRU_JWT_SECRET overrides Secret.
+//
+// The permissions of a token come from its ScopeClaim, "scope" by
+// default, holding a space-separated string or an array of values. Scopes
+// maps those values to "read", "write" or "admin"; without it the values
+// are taken as scope names themselves. A token is granted the widest
+// scope among its values.
+type jwtSettings struct {
+	Secret     string            `json:"secret"`
+	JWKSURL    string            `json:"jwks_url"`
+	Issuer     string            `json:"issuer"`
+	Audience   string            `json:"audience"`
+	ScopeClaim string            `json:"scope_claim"`
+	Scopes     map[string]string `json:"scopes"`
+}
+
+// enabled reports whether bearer tokens are accepted
+func (s jwtSettings) enabled() bool {
+	return s.Secret != "" || s.JWKSURL != ""
+}
+
+// validate checks s for conflicting or unknown settings
+func (s jwtSettings) validate() error {
+	if s.Secret != "" && s.JWKSURL != "" {
+		return errors.New("jwt: secret and jwks_url are exclusive")
+	}
+	for value, name := range s.Scopes {
+		if _, known := scopes[name]; !known {
+			return fmt.Errorf("jwt: unknown scope %q for %q", name, value)
+		}
+	}
+	return nil
+}
+
+// jwksClient fetches JWKS documents
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// jwksRefresh is how long a JWKS document is used before a token signed
+// with an unknown key makes it fetched again
+const jwksRefresh = time.Minute
+
+// jwtVerifier validates bearer tokens as configured by its settings
+type jwtVerifier struct {
+	settings jwtSettings
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey // by key ID, from the JWKS document
+	fetched  time.Time
+	fetching chan struct{} // closed when the fetch in flight ends, nil if none is
+}
+
+// newJWTVerifier returns a verifier for s, or nil if s is not enabled
+func newJWTVerifier(s jwtSettings) *jwtVerifier {
+	if !s.enabled() {
+		return nil
+	}
+	if s.ScopeClaim == "" {
+		s.ScopeClaim = "scope"
+	}
+	return &jwtVerifier{settings: s}
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks the signature and claims of token and returns the scope
+// it grants and its subject, if it has one
+func (v *jwtVerifier) verify(token string) (scope, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, "", errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return 0, "", err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, "", errors.New("malformed signature")
+	}
+	if err := v.verifySignature(header, parts[0]+"."+parts[1], sig); err != nil {
+		return 0, "", err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return 0, "", err
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return 0, "", err
+	}
+	subject, _ := claims["sub"].(string)
+	return v.grantedScope(claims[v.settings.ScopeClaim]), subject, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON token segment into dst
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// verifySignature checks sig over signed. The algorithm must match the
+// kind of key configured, so that a token cannot pick a weaker check.
+func (v *jwtVerifier) verifySignature(header jwtHeader, signed string, sig []byte) error {
+	if v.settings.Secret != "" {
+		if header.Alg != "HS256" {
+			return fmt.Errorf("unsupported algorithm %q", header.Alg)
+		}
+		mac := hmac.New(sha256.New, []byte(v.settings.Secret))
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(signed))
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 {
+			return errors.New("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// checkClaims checks the expiry, not-before, issuer and audience claims.
+// Tokens without an expiry are rejected.
+func (v *jwtVerifier) checkClaims(claims map[string]any, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.Unix() >= int64(exp) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Unix() < int64(nbf) {
+		return errors.New("token not yet valid")
+	}
+	if v.settings.Issuer != "" && claims["iss"] != v.settings.Issuer {
+		return errors.New("wrong issuer")
+	}
+	if v.settings.Audience != "" {
+		switch aud := claims["aud"].(type) {
+		case string:
+			if aud == v.settings.Audience {
+				return nil
+			}
+		case []any:
+			if slices.Contains(aud, any(v.settings.Audience)) {
+				return nil
+			}
+		}
+		return errors.New("wrong audience")
+	}
+	return nil
+}
+
+// grantedScope returns the widest scope mapped from the values of the
+// scope claim, or 0 if none is
+func (v *jwtVerifier) grantedScope(claim any) scope {
+	var values []string
+	switch claim := claim.(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []any:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var granted scope
+	for _, value := range values {
+		name := value
+		if v.settings.Scopes != nil {
+			name = v.settings.Scopes[value]
+		}
+		granted = max(granted, scopes[name])
+	}
+	return granted
+}
+
+// key returns the JWKS key with ID kid, fetching the document if the key
+// is unknown and no fetch was attempted recently. The document is fetched
+// without holding v.mu, so that tokens signed with known keys are verified
+// meanwhile; tokens with unknown keys wait for the fetch in flight.
+func (v *jwtVerifier) key(kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	if key, found := v.keys[kid]; found {
+		v.mu.Unlock()
+		return key, nil
+	}
+	var err error
+	if fetching := v.fetching; fetching != nil {
+		v.mu.Unlock()
+		<-fetching
+	} else {
+		if time.Since(v.fetched) < jwksRefresh {
+			v.mu.Unlock()
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		fetching = make(chan struct{})
+		v.fetching, v.fetched = fetching, time.Now()
+		v.mu.Unlock()
+
+		var keys map[string]crypto.PublicKey
+		keys, err = fetchJWKS(v.settings.JWKSURL)
+		v.mu.Lock()
+		if err == nil {
+			v.keys = keys
+		}
+		v.fetching = nil
+		close(fetching)
+		v.mu.Unlock()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, found := v.keys[kid]; found {
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// jwk is a key in a JWKS document. Only RSA keys and EC keys on P-256
+// are used; others are skipped.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchJWKS returns the usable keys of the JWKS document at url by key ID
+func fetchJWKS(url string) (map[string]crypto.PublicKey, error) {
+	resp, err := jwksClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: %s", resp.Status)
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range doc.Keys {
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey decodes k
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding
+	switch {
+	case k.Kty == "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := b64.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(k.Y)
+		if err != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid EC point")
+		}
+		// ecdh rejects points that are not on the curve
+		point := bytes.Join([][]byte{{4}, x, y}, nil)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// segment encodes v as a token segment
+func segment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signToken returns a token with header and claims, signed with key as
+// alg; "none" leaves the signature empty
+func signToken(t *testing.T, alg string, key any, header, claims map[string]any) string {
+	t.Helper()
+	if header == nil {
+		header = map[string]any{}
+	}
+	header["alg"] = alg
+	signed := segment(t, header) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "none":
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	default:
+		t.Fatalf("unknown algorithm %q", alg)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// claims returns valid claims granting scope, overridden in turn by each
+// of extras; a nil value removes a claim
+func claims(scope string, extras ...map[string]any) map[string]any {
+	c := map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "scope": scope, "sub": "alice"}
+	for _, extra := range extras {
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+	}
+	return c
+}
+
+// replaceSegment returns token with its segment i replaced by that of v
+func replaceSegment(t *testing.T, token string, i int, v any) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	parts[i] = segment(t, v)
+	return strings.Join(parts, ".")
+}
+
+func TestVerifyHS256(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newJWTVerifier(jwtSettings{Secret: testSecret, Issuer: "issuer", Audience: "lru"})
+	secret := []byte(testSecret)
+	valid := map[string]any{"iss": "issuer", "aud": "lru"}
+	with := func(extra map[string]any) map[string]any { return claims("write", valid, extra) }
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		token string
+		want  scope // 0 if the token must be rejected
+	}{
+		{"valid", signToken(t, "HS256", secret, nil, with(nil)), scopeWrite},
+		{"audience in array", signToken(t, "HS256", secret, nil, with(map[string]any{"aud": []any{"other", "lru"}})), scopeWrite},
+		{"none algorithm", signToken(t, "none", nil, nil, with(nil)), 0},
+		{"RS256 against a secret", signToken(t, "RS256", rsaKey, nil, with(nil)), 0},
+		{"HS384 header", replaceSegment(t, signToken(t, "HS256", secret, nil, with(nil)), 0, map[string]any{"alg": "HS384"}), 0},
+		{"wrong secret", signToken(t, "HS256", []byte("other"), nil, with(nil)), 0},
+		{"tampered claims", replaceSegment(t, signToken(t, "HS256", secret, nil, with(nil)), 1, claims("admin", valid)), 0},
+		{"expired", signToken(t, "HS256", secret, nil, with(map[string]any{"exp": now.Add(-time.Minute).Unix()})), 0},
+		{"no expiry", signToken(t, "HS256", secret, nil, with(map[string]any{"exp": nil})), 0},
+		{"not yet valid", signToken(t, "HS256", secret, nil, with(map[string]any{"nbf": now.Add(time.Hour).Unix()})), 0},
+		{"valid after nbf", signToken(t, "HS256", secret, nil, with(map[string]any{"nbf": now.Add(-time.Hour).Unix()})), scopeWrite},
+		{"wrong issuer", signToken(t, "HS256", secret, nil, with(map[string]any{"iss": "other"})), 0},
+		{"no issuer", signToken(t, "HS256", secret, nil, with(map[string]any{"iss": nil})), 0},
+		{"wrong audience", signToken(t, "HS256", secret, nil, with(map[string]any{"aud": "other"})), 0},
+		{"audience array without lru", signToken(t, "HS256", secret, nil, with(map[string]any{"aud": []any{"other"}})), 0},
+		{"unknown scope", signToken(t, "HS256", secret, nil, with(map[string]any{"scope": "superuser"})), 0},
+		{"widest scope", signToken(t, "HS256", secret, nil, with(map[string]any{"scope": []any{"read", "admin"}})), scopeAdmin},
+		{"malformed", "not.a-token", 0},
+		{"bad signature encoding", signToken(t, "HS256", secret, nil, with(nil)) + "!", 0},
+	}
+	for _, tt := range tests {
+		got, subject, err := v.verify(tt.token)
+		switch {
+		case tt.want == 0 && got != 0:
+			t.Errorf("%s: verify granted %v, want rejection", tt.name, got)
+		case tt.want != 0 && (err != nil || got != tt.want):
+			t.Errorf("%s: verify = %v, %v, want %v", tt.name, got, err, tt.want)
+		case tt.want != 0 && subject != "alice":
+			t.Errorf("%s: subject = %q, want alice", tt.name, subject)
+		}
+	}
+}
+
+// jwksServer serves a JWKS document holding keys and counts the requests
+// for it
+func jwksServer(t *testing.T, keys map[string]crypto.PublicKey, fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+	b64 := base64.RawURLEncoding
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			doc.Keys = append(doc.Keys, jwk{Kty: "RSA", Kid: kid, N: b64.EncodeToString(key.N.Bytes()), E: b64.EncodeToString(big.NewInt(int64(key.E)).Bytes())})
+		case *ecdsa.PublicKey:
+			x, y := make([]byte, 32), make([]byte, 32)
+			key.X.FillBytes(x)
+			key.Y.FillBytes(y)
+			doc.Keys = append(doc.Keys, jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: b64.EncodeToString(x), Y: b64.EncodeToString(y)})
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(doc)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	srv := jwksServer(t, map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey}, &fetches)
+	v := newJWTVerifier(jwtSettings{JWKSURL: srv.URL})
+	kid := func(id string) map[string]any { return map[string]any{"kid": id} }
+
+	tests := []struct {
+		name  string
+		token string
+		want  scope
+	}{
+		{"RS256", signToken(t, "RS256", rsaKey, kid("rsa"), claims("read", nil)), scopeRead},
+		{"ES256", signToken(t, "ES256", ecKey, kid("ec"), claims("admin", nil)), scopeAdmin},
+		{"none", signToken(t, "none", nil, kid("rsa"), claims("read", nil)), 0},
+		{"HS256 with the public key as secret", signToken(t, "HS256", rsaKey.PublicKey.N.Bytes(), kid("rsa"), claims("read", nil)), 0},
+		{"ES256 claimed for an RSA key", signToken(t, "ES256", ecKey, kid("rsa"), claims("read", nil)), 0},
+		{"RS256 claimed for an EC key", signToken(t, "RS256", rsaKey, kid("ec"), claims("read", nil)), 0},
+		{"signed by another key", signToken(t, "RS256", otherRSA, kid("rsa"), claims("read", nil)), 0},
+		{"expired", signToken(t, "RS256", rsaKey, kid("rsa"), claims("read", map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})), 0},
+	}
+	for _, tt := range tests {
+		got, _, err := v.verify(tt.token)
+		switch {
+		case tt.want == 0 && got != 0:
+			t.Errorf("%s: verify granted %v, want rejection", tt.name, got)
+		case tt.want != 0 && (err != nil || got != tt.want):
+			t.Errorf("%s: verify = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times for known keys, want 1", n)
+	}
+}
+
+func TestJWKSRefetchThrottled(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	srv := jwksServer(t, map[string]crypto.PublicKey{"known": &key.PublicKey}, &fetches)
+	v := newJWTVerifier(jwtSettings{JWKSURL: srv.URL})
+	unknown := signToken(t, "ES256", key, map[string]any{"kid": "unknown"}, claims("read", nil))
+
+	for range 3 {
+		if got, _, err := v.verify(unknown); got != 0 || err == nil {
+			t.Fatalf("token with an unknown kid verified: %v, %v", got, err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times for repeated unknown kids, want 1", n)
+	}
+
+	// Once the refresh interval has passed, an unknown kid fetches again
+	v.mu.Lock()
+	v.fetched = time.Now().Add(-jwksRefresh)
+	v.mu.Unlock()
+	v.verify(unknown)
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times after the refresh interval, want 2", n)
+	}
+
+	known := signToken(t, "ES256", key, map[string]any{"kid": "known"}, claims("read", nil))
+	if got, _, err := v.verify(known); got != scopeRead || err != nil {
+		t.Errorf("token with a known kid: %v, %v", got, err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times for a known kid, want 2", n)
+	}
+}
+
+func TestJWKSFetchDoesNotBlockKnownKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	upstream := jwksServer(t, map[string]crypto.PublicKey{"known": &key.PublicKey}, &fetches)
+	entered, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Load() > 0 {
+			close(entered)
+			<-release
+		}
+		resp, err := http.Get(upstream.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+	unblock := sync.OnceFunc(func() { close(release) })
+	t.Cleanup(unblock) // before srv.Close, which waits for the handler
+
+	v := newJWTVerifier(jwtSettings{JWKSURL: srv.URL})
+	known := signToken(t, "ES256", key, map[string]any{"kid": "known"}, claims("read", nil))
+	if got, _, err := v.verify(known); got != scopeRead || err != nil {
+		t.Fatalf("token with a known kid: %v, %v", got, err)
+	}
+
+	// An unknown kid starts a fetch that stalls
+	v.mu.Lock()
+	v.fetched = time.Now().Add(-jwksRefresh)
+	v.mu.Unlock()
+	unknown := signToken(t, "ES256", key, map[string]any{"kid": "unknown"}, claims("read", nil))
+	results := make(chan error, 2)
+	verifyUnknown := func() {
+		_, _, err := v.verify(unknown)
+		results <- err
+	}
+	go verifyUnknown()
+	<-entered
+	go verifyUnknown()
+
+	verified := make(chan error)
+	go func() {
+		_, _, err := v.verify(known)
+		verified <- err
+	}()
+	select {
+	case err := <-verified:
+		if err != nil {
+			t.Errorf("token with a known kid during a fetch: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("token with a known kid waited for a JWKS fetch")
+	}
+
+	unblock()
+	for range 2 {
+		if err := <-results; err == nil {
+			t.Error("token with an unknown kid verified")
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
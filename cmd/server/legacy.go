@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// apiVersion prefixes the paths of the stable API. The same routes are
+// also served without it, for clients written before it was introduced.
+const apiVersion = "/v1"
+
+// legacyRoutes lists the per-cache endpoints kept for clients of the
+// original API, which are not served under apiVersion
+var legacyRoutes = []route{
+	{http.MethodGet, "/get", LegacyGetHandler, scopeRead},
+	{http.MethodPost, "/set", LegacySetHandler, scopeWrite},
+}
+
+// deprecate marks the response to the legacy request r as deprecated,
+// linking to the resource of key that replaces it
+func deprecate(w http.ResponseWriter, r *http.Request, key string) {
+	successor := apiVersion
+	if name := r.PathValue("name"); name != "" {
+		successor += "/caches/" + url.PathEscape(name)
+	}
+	successor += "/cache/" + url.PathEscape(key)
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+}
+
+// LegacyGetHandler handles GET requests naming the key in the key query
+// parameter, as GET /v1/cache/{key} does for its path segment
+func LegacyGetHandler(cache *Cache) http.HandlerFunc {
+	get := GetHandler(cache)
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := queryKey(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		deprecate(w, r, key)
+		r.SetPathValue("key", key)
+		get(w, r)
+	}
+}
+
+// LegacySetHandler handles POST requests storing a SetRequest that names
+// its key. Unlike PUT /v1/cache/{key} it responds 201 whether or not the
+// key was cached before.
+func LegacySetHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var item SetRequest
+		err := json.NewDecoder(r.Body).Decode(&item)
+		if err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := item.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		deprecate(w, r, item.Key)
+		status := store(cache, item)
+		if status == http.StatusNoContent {
+			status = http.StatusCreated
+		}
+		w.WriteHeader(status)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/http"
+)
+
+// LimitBody wraps handler to bound request bodies to limit bytes. Requests
+// declaring a larger body get 413 straight away; reading past limit from
+// a body of unknown length fails, so the handler rejects the request.
+func LimitBody(limit int64, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		handler.ServeHTTP(w, r)
+	})
+}
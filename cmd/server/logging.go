@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// logSettings configures the server's logs. Level is "debug", "info",
+// the default, "warn" or "error", and Format is "text", the default, or
+// "json".
+type logSettings struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+}
+
+// level returns the slog level named by s
+func (s logSettings) level() (slog.Level, error) {
+	var level slog.Level
+	if s.Level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s.Level)); err != nil {
+		return 0, fmt.Errorf("log: unknown level %q", s.Level)
+	}
+	return level, nil
+}
+
+// validate checks that s names a known level and format
+func (s logSettings) validate() error {
+	if _, err := s.level(); err != nil {
+		return err
+	}
+	if s.Format != "" && s.Format != "text" && s.Format != "json" {
+		return fmt.Errorf("log: unknown format %q", s.Format)
+	}
+	return nil
+}
+
+// newLogger returns a logger writing to standard error in the format of
+// s, filtered by level
+func newLogger(s logSettings, level *slog.LevelVar) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if s.Format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+// statusRecorder records the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records status
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write records a 200 status if none was written and the size of b
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// AccessLog wraps handler to log every request with its method, path,
+// key, status, response size, latency and client address, and the ID of
+// its trace if it is traced. Server errors
+// are logged at the error level and the rest at the info level.
+func AccessLog(logger *slog.Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// The mux has matched r by now, so path values are set
+		key := r.PathValue("key")
+		if key == "" {
+			key = r.URL.Query().Get("key")
+		}
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("key", key),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client", client),
+		}
+		if sp := spanFrom(r.Context()); sp != nil {
+			attrs = append(attrs, slog.String("trace_id", hex.EncodeToString(sp.traceID[:])))
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// route is a per-cache endpoint. It is served for the default cache at
+// path and for every named cache under /caches/{name}, both under
+// apiVersion and without it.
+type route struct {
+	method  string // empty if the handler checks the method itself
+	path    string
+	handler func(*Cache) http.HandlerFunc
+	scope   scope // required of the request's API key
+}
+
+// routes lists the per-cache endpoints
+var routes = []route{
+	{http.MethodGet, "/cache/{key}", GetHandler, scopeRead},
+	{http.MethodPut, "/cache/{key}", SetHandler, scopeWrite},
+	{http.MethodPost, "/getdel", GetDelHandler, scopeWrite},
+	{http.MethodGet, "/info", InfoHandler, scopeRead},
+	{http.MethodGet, "/ttl", TTLHandler, scopeRead},
+	{http.MethodPost, "/touch", TouchHandler, scopeWrite},
+	{http.MethodPost, "/expire", ExpireHandler, scopeWrite},
+	{http.MethodPost, "/persist", PersistHandler, scopeWrite},
+	{http.MethodGet, "/keys", KeysHandler, scopeRead},
+	{http.MethodGet, "/scan", ScanHandler, scopeRead},
+	{http.MethodGet, "/mget", MGetHandler, scopeRead},
+	{http.MethodPost, "/mset", MSetHandler, scopeWrite},
+	{http.MethodPost, "/pipeline", PipelineHandler, scopeWrite},
+	{http.MethodDelete, "/cache/{key}", DeleteHandler, scopeWrite},
+	{http.MethodHead, "/cache/{key}", HeadHandler, scopeRead},
+	{http.MethodGet, "/stats", StatsHandler, scopeRead},
+	{http.MethodGet, "/stats/hotkeys", HotKeysHandler, scopeRead},
+	{http.MethodPost, "/stats/reset", ResetStatsHandler, scopeAdmin},
+	{http.MethodPost, "/flush", FlushHandler, scopeAdmin},
+	{http.MethodPut, "/admin/capacity", CapacityHandler, scopeAdmin},
+	{http.MethodPut, "/admin/ttl", DefaultTTLHandler, scopeAdmin},
+}
+
+// pattern returns the ServeMux pattern for rt mounted under prefix
+func (rt route) pattern(prefix string) string {
+	if rt.method == "" {
+		return prefix + rt.path
+	}
+	return rt.method + " " + prefix + rt.path
+}
+
+func main() {
+	cfg, err := parseConfig(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	origin = cfg.Origin
+
+	var level slog.LevelVar
+	lvl, _ := cfg.Log.level() // validated by parseConfig
+	level.Set(lvl)
+	logger := newLogger(cfg.Log, &level)
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Probes are served while the caches are created, so readiness can
+	// report the server as starting
+	var ready atomic.Bool
+	registry := NewRegistry()
+	// The API has a mux of its own, as net/http/pprof and expvar register
+	// their handlers with the default one
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", HealthzHandler())
+	mux.HandleFunc("GET /readyz", ReadyzHandler(registry, &ready))
+	mux.HandleFunc("GET /openapi.json", OpenAPIHandler())
+	keys, err := cfg.apiKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth, err := NewAuthenticator(keys, cfg.JWT, cfg.TLS)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limiter := NewRateLimiter(cfg.RateLimit, auth)
+	// guard rate limits a handler and requires scope s of the client
+	guard := func(s scope, handler http.HandlerFunc) http.HandlerFunc {
+		return limiter.Limit(auth.Require(s, handler))
+	}
+	metrics := NewMetrics(registry)
+	tracer := NewTracer(cfg.Tracing)
+	mux.HandleFunc("GET /metrics", guard(scopeRead, MetricsHandler(metrics)))
+	srv := &http.Server{
+		Addr:           cfg.Addr,
+		Handler:        tracer.Trace(AccessLog(logger, metrics.Instrument(CORS(cfg.CORS, LimitBody(cfg.MaxBodyBytes, Gzip(Negotiate(mux))))))),
+		ReadTimeout:    time.Duration(cfg.ReadTimeout),
+		WriteTimeout:   time.Duration(cfg.WriteTimeout),
+		IdleTimeout:    time.Duration(cfg.IdleTimeout),
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+	srv.TLSConfig, _ = cfg.TLS.config() // validated by parseConfig
+	go func() {
+		var err error
+		if cfg.TLS.enabled() {
+			err = srv.ListenAndServeTLS(cfg.TLS.Cert, cfg.TLS.Key)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	// The debug listener has no write timeout, as CPU profiles and traces
+	// take as long as they are asked to
+	debugSrv := &http.Server{Addr: cfg.DebugAddr, Handler: DebugHandler(), ReadHeaderTimeout: time.Duration(cfg.ReadTimeout)}
+	if cfg.DebugAddr != "" {
+		publishCaches(registry)
+		go func() {
+			if err := debugSrv.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	reqs, err := cfg.cacheRequests()
+	if err != nil {
+		log.Fatal(err)
+	}
+	registry.Configure(reqs)
+	cache, _ := registry.Get(defaultNamespace)
+
+	reloader := NewReloader(os.Args, registry, auth, limiter, &level)
+	mount := func(prefix string, routes []route) {
+		for _, rt := range routes {
+			mux.HandleFunc(rt.pattern(prefix), guard(rt.scope, rt.handler(cache)))
+			mux.HandleFunc(rt.pattern(prefix+"/caches/{name}"), guard(rt.scope, registry.Handle(rt.handler)))
+		}
+	}
+	for _, prefix := range []string{apiVersion, ""} {
+		mount(prefix, routes)
+		mux.HandleFunc("GET "+prefix+"/caches", guard(scopeRead, ListCachesHandler(registry)))
+		mux.HandleFunc("POST "+prefix+"/caches", guard(scopeAdmin, CreateCacheHandler(registry)))
+		mux.HandleFunc("POST "+prefix+"/admin/reload", guard(scopeAdmin, ReloadHandler(reloader)))
+	}
+	mount("", legacyRoutes)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloader.Reload(); err != nil {
+				slog.Error("reload failed", "err", err)
+				continue
+			}
+			slog.Info("configuration reloaded")
+		}
+	}()
+
+	if cfg.Snapshot != "" {
+		if err := registry.LoadSnapshot(cfg.Snapshot); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ready.Store(true)
+	slog.Info("server is running", "addr", cfg.Addr, "tls", cfg.TLS.enabled())
+	<-ctx.Done()
+	stop()
+
+	// Stop accepting connections and let in-flight requests finish before
+	// the caches are saved and closed
+	slog.Info("shutting down")
+	ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("shutdown failed", "err", err)
+	}
+	debugSrv.Close()
+	tracer.Close()
+	if cfg.Snapshot != "" {
+		if err := registry.SaveSnapshot(cfg.Snapshot); err != nil {
+			slog.Error("saving snapshot failed", "err", err)
+		}
+	}
+	registry.Close()
+}
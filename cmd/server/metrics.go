@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds in seconds of the request latency
+// histogram buckets
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// endpointKey identifies the requests counted together
+type endpointKey struct {
+	pattern string // the ServeMux pattern matched, "unmatched" if none
+	code    int
+}
+
+// histogram counts observations into latencyBuckets
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative, with one for +Inf
+	sum    float64
+	count  uint64
+}
+
+// Metrics collects the request counters and latencies of the server and
+// exports them, along with the caches' counters, in the Prometheus text
+// exposition format
+type Metrics struct {
+	reg *Registry
+
+	mu        sync.Mutex
+	requests  map[endpointKey]uint64
+	latencies map[string]*histogram // by pattern
+}
+
+// NewMetrics initializes Metrics reporting on the caches of reg
+func NewMetrics(reg *Registry) *Metrics {
+	return &Metrics{reg: reg, requests: map[endpointKey]uint64{}, latencies: map[string]*histogram{}}
+}
+
+// observe records a request to pattern answered with code after latency
+func (m *Metrics) observe(pattern string, code int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[endpointKey{pattern, code}]++
+	h := m.latencies[pattern]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets)+1)}
+		m.latencies[pattern] = h
+	}
+	seconds := latency.Seconds()
+	h.counts[sort.SearchFloat64s(latencyBuckets, seconds)]++
+	h.sum += seconds
+	h.count++
+}
+
+// snapshot returns a copy of the request counters and latencies, so that
+// they can be written out without holding m.mu while the client reads
+func (m *Metrics) snapshot() (map[endpointKey]uint64, map[string]histogram) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latencies := make(map[string]histogram, len(m.latencies))
+	for pattern, h := range m.latencies {
+		latencies[pattern] = histogram{slices.Clone(h.counts), h.sum, h.count}
+	}
+	return maps.Clone(m.requests), latencies
+}
+
+// Instrument wraps handler to count requests and time them by the
+// pattern they matched and the status they were answered with
+func (m *Metrics) Instrument(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		// The mux has matched r by now, so its pattern is set. Paths
+		// that match nothing are counted together, so that scanners
+		// cannot grow the series without bound.
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		m.observe(pattern, rec.status, time.Since(start))
+	})
+}
+
+// escapeLabel escapes a label value for the exposition format
+var escapeLabel = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+// write writes the metrics to w in the text exposition format
+func (m *Metrics) write(w *bufio.Writer) {
+	family := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	type cacheMetric struct {
+		name, kind, help string
+		value            func(*Cache) float64
+	}
+	cacheMetrics := []cacheMetric{
+		{"lru_cache_hits_total", "counter", "Lookups that found a live entry.", func(c *Cache) float64 { return float64(c.Stats().Hits) }},
+		{"lru_cache_misses_total", "counter", "Lookups that found no live entry.", func(c *Cache) float64 { return float64(c.Stats().Misses) }},
+		{"lru_cache_sets_total", "counter", "Entries stored.", func(c *Cache) float64 { return float64(c.Stats().Sets) }},
+		{"lru_cache_evictions_total", "counter", "Entries removed to make room.", func(c *Cache) float64 { return float64(c.Stats().Evictions) }},
+		{"lru_cache_expirations_total", "counter", "Entries removed after their TTL.", func(c *Cache) float64 { return float64(c.Stats().Expirations) }},
+		{"lru_cache_entries", "gauge", "Entries held.", func(c *Cache) float64 { return float64(c.Len()) }},
+		{"lru_cache_capacity", "gauge", "Most entries the cache holds.", func(c *Cache) float64 { return float64(c.Cap()) }},
+		{"lru_cache_bytes", "gauge", "Estimated memory held by the entries in bytes.", func(c *Cache) float64 { return float64(c.Bytes()) }},
+	}
+	var caches []*Cache
+	names := m.reg.Names()
+	for _, name := range names {
+		cache, _ := m.reg.Get(name)
+		caches = append(caches, cache)
+	}
+	for _, cm := range cacheMetrics {
+		family(cm.name, cm.kind, cm.help)
+		for i, cache := range caches {
+			if cache != nil {
+				fmt.Fprintf(w, "%s{cache=\"%s\"} %s\n", cm.name, escapeLabel(names[i]), formatFloat(cm.value(cache)))
+			}
+		}
+	}
+
+	requests, latencies := m.snapshot()
+	keys := make([]endpointKey, 0, len(requests))
+	for key := range requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].pattern != keys[j].pattern {
+			return keys[i].pattern < keys[j].pattern
+		}
+		return keys[i].code < keys[j].code
+	})
+	family("lru_http_requests_total", "counter", "HTTP requests by the route they matched and their status code.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "lru_http_requests_total{pattern=\"%s\",code=\"%d\"} %d\n", escapeLabel(key.pattern), key.code, requests[key])
+	}
+
+	patterns := make([]string, 0, len(latencies))
+	for pattern := range latencies {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	family("lru_http_request_duration_seconds", "histogram", "HTTP request latencies by the route they matched.")
+	for _, pattern := range patterns {
+		h, label := latencies[pattern], escapeLabel(pattern)
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = formatFloat(latencyBuckets[i])
+			}
+			fmt.Fprintf(w, "lru_http_request_duration_seconds_bucket{pattern=\"%s\",le=\"%s\"} %d\n", label, le, cumulative)
+		}
+		fmt.Fprintf(w, "lru_http_request_duration_seconds_sum{pattern=\"%s\"} %s\n", label, formatFloat(h.sum))
+		fmt.Fprintf(w, "lru_http_request_duration_seconds_count{pattern=\"%s\"} %d\n", label, h.count)
+	}
+}
+
+// formatFloat formats f as the exposition format expects
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// MetricsHandler handles GET requests for the metrics of m
+func MetricsHandler(m *Metrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		m.write(bw)
+		bw.Flush()
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	reg := NewRegistry()
+	reg.Create("default", 10, 0)
+	m := NewMetrics(reg)
+	m.observe("GET /cache/{key}", 200, 3*time.Millisecond)
+	m.observe("GET /cache/{key}", 404, time.Second)
+
+	var out strings.Builder
+	w := bufio.NewWriter(&out)
+	m.write(w)
+	w.Flush()
+	for _, line := range []string{
+		`lru_cache_capacity{cache="default"} 10`,
+		`lru_http_requests_total{pattern="GET /cache/{key}",code="200"} 1`,
+		`lru_http_requests_total{pattern="GET /cache/{key}",code="404"} 1`,
+		`lru_http_request_duration_seconds_bucket{pattern="GET /cache/{key}",le="0.0025"} 0`,
+		`lru_http_request_duration_seconds_bucket{pattern="GET /cache/{key}",le="0.005"} 1`,
+		`lru_http_request_duration_seconds_bucket{pattern="GET /cache/{key}",le="+Inf"} 2`,
+		`lru_http_request_duration_seconds_count{pattern="GET /cache/{key}"} 2`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("metrics lack %q", line)
+		}
+	}
+}
+
+func TestMetricsStalledScraper(t *testing.T) {
+	m := NewMetrics(NewRegistry())
+	for i := range 100 {
+		m.observe(fmt.Sprintf("GET /route%d", i), 200, time.Millisecond)
+	}
+
+	// The scraper stops reading once the request counters start, leaving
+	// write blocked partway through them
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go m.write(bufio.NewWriter(pw))
+	scraper := bufio.NewReader(pr)
+	for {
+		line, err := scraper.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "lru_http_requests_total{") {
+			break
+		}
+	}
+
+	observed := make(chan struct{})
+	go func() {
+		m.observe("GET /other", 200, time.Millisecond)
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("observe blocked by a stalled metrics write")
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// msgpackType is the media type of MessagePack bodies
+const msgpackType = "application/msgpack"
+
+// maxMsgpackDepth bounds the nesting of MessagePack arrays and maps
+const maxMsgpackDepth = 1000
+
+// errMsgpack is returned for MessagePack input that cannot be converted to
+// JSON
+var errMsgpack = errors.New("invalid or unsupported MessagePack")
+
+// jsonToMsgpack converts the JSON document data to MessagePack, keeping
+// the order of object members. Integers are encoded as such and other
+// numbers as float64.
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out []byte
+	if err := encodeMsgpack(dec, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// encodeMsgpack appends the next JSON value read from dec to out
+func encodeMsgpack(dec *json.Decoder, out *[]byte) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		*out = append(*out, 0xc0)
+	case bool:
+		if tok {
+			*out = append(*out, 0xc3)
+		} else {
+			*out = append(*out, 0xc2)
+		}
+	case json.Number:
+		appendNumber(out, tok)
+	case string:
+		appendString(out, tok)
+	case json.Delim:
+		// Encode the members first, since their count precedes them
+		var members []byte
+		n := 0
+		for dec.More() {
+			if tok == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				appendString(&members, key.(string))
+			}
+			if err := encodeMsgpack(dec, &members); err != nil {
+				return err
+			}
+			n++
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		if tok == '{' {
+			appendLength(out, n, 0x80, 0xde)
+		} else {
+			appendLength(out, n, 0x90, 0xdc)
+		}
+		*out = append(*out, members...)
+	}
+	return nil
+}
+
+// appendNumber appends n as the smallest MessagePack integer holding it,
+// or as a float64 if it is not an integer
+func appendNumber(out *[]byte, n json.Number) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		switch {
+		case i >= 0 && i <= 0x7f:
+			*out = append(*out, byte(i))
+		case i < 0 && i >= -32:
+			*out = append(*out, byte(i))
+		case i >= math.MinInt8 && i <= math.MaxInt8:
+			*out = append(*out, 0xd0, byte(i))
+		case i >= math.MinInt16 && i <= math.MaxInt16:
+			*out = binary.BigEndian.AppendUint16(append(*out, 0xd1), uint16(i))
+		case i >= math.MinInt32 && i <= math.MaxInt32:
+			*out = binary.BigEndian.AppendUint32(append(*out, 0xd2), uint32(i))
+		default:
+			*out = binary.BigEndian.AppendUint64(append(*out, 0xd3), uint64(i))
+		}
+		return
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		*out = binary.BigEndian.AppendUint64(append(*out, 0xcf), u)
+		return
+	}
+	f, _ := n.Float64()
+	*out = binary.BigEndian.AppendUint64(append(*out, 0xcb), math.Float64bits(f))
+}
+
+// appendString appends s as a MessagePack string
+func appendString(out *[]byte, s string) {
+	switch n := len(s); {
+	case n < 32:
+		*out = append(*out, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		*out = append(*out, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		*out = binary.BigEndian.AppendUint16(append(*out, 0xda), uint16(n))
+	default:
+		*out = binary.BigEndian.AppendUint32(append(*out, 0xdb), uint32(n))
+	}
+	*out = append(*out, s...)
+}
+
+// appendLength appends the header of an array or map of n members, given
+// the prefix of its fixed form and the code of its 16-bit form; the
+// 32-bit form's code follows it
+func appendLength(out *[]byte, n int, fixed, code16 byte) {
+	switch {
+	case n < 16:
+		*out = append(*out, fixed|byte(n))
+	case n <= math.MaxUint16:
+		*out = binary.BigEndian.AppendUint16(append(*out, code16), uint16(n))
+	default:
+		*out = binary.BigEndian.AppendUint32(append(*out, code16+1), uint32(n))
+	}
+}
+
+// msgpackReader converts MessagePack to JSON
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+// msgpackToJSON converts the MessagePack document data to JSON. Binary
+// strings become base64 strings, map keys must be strings, and extension
+// types and non-finite floats are rejected.
+func msgpackToJSON(data []byte) ([]byte, error) {
+	mr := &msgpackReader{data: data}
+	var out bytes.Buffer
+	if err := mr.value(&out, 0); err != nil {
+		return nil, err
+	}
+	if mr.pos != len(data) {
+		return nil, errMsgpack
+	}
+	return out.Bytes(), nil
+}
+
+// next returns the following n bytes
+func (mr *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(mr.data)-mr.pos < n {
+		return nil, errMsgpack
+	}
+	b := mr.data[mr.pos : mr.pos+n]
+	mr.pos += n
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes
+func (mr *msgpackReader) uint(size int) (uint64, error) {
+	b, err := mr.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// value converts the next value to JSON, written to out
+func (mr *msgpackReader) value(out *bytes.Buffer, depth int) error {
+	if depth > maxMsgpackDepth {
+		return errMsgpack
+	}
+	b, err := mr.next(1)
+	if err != nil {
+		return err
+	}
+	switch c := b[0]; {
+	case c <= 0x7f:
+		out.WriteString(strconv.Itoa(int(c)))
+	case c >= 0xe0:
+		out.WriteString(strconv.Itoa(int(int8(c))))
+	case c&0xf0 == 0x80:
+		return mr.object(out, int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return mr.array(out, int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return mr.str(out, int(c&0x1f))
+	case c == 0xc0:
+		out.WriteString("null")
+	case c == 0xc2:
+		out.WriteString("false")
+	case c == 0xc3:
+		out.WriteString("true")
+	case c >= 0xc4 && c <= 0xc6: // bin 8, 16, 32
+		n, err := mr.uint(1 << (c - 0xc4))
+		if err != nil {
+			return err
+		}
+		bin, err := mr.next(int(n))
+		if err != nil {
+			return err
+		}
+		out.WriteString(`"` + base64.StdEncoding.EncodeToString(bin) + `"`)
+	case c == 0xca, c == 0xcb: // float 32, 64
+		var f float64
+		if c == 0xca {
+			u, err := mr.uint(4)
+			if err != nil {
+				return err
+			}
+			f = float64(math.Float32frombits(uint32(u)))
+		} else {
+			u, err := mr.uint(8)
+			if err != nil {
+				return err
+			}
+			f = math.Float64frombits(u)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return errMsgpack
+		}
+		out.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case c >= 0xcc && c <= 0xcf: // uint 8, 16, 32, 64
+		u, err := mr.uint(1 << (c - 0xcc))
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatUint(u, 10))
+	case c >= 0xd0 && c <= 0xd3: // int 8, 16, 32, 64
+		size := 1 << (c - 0xd0)
+		u, err := mr.uint(size)
+		if err != nil {
+			return err
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*size
+		out.WriteString(strconv.FormatInt(int64(u<<shift)>>shift, 10))
+	case c >= 0xd9 && c <= 0xdb: // str 8, 16, 32
+		n, err := mr.uint(1 << (c - 0xd9))
+		if err != nil {
+			return err
+		}
+		return mr.str(out, int(n))
+	case c == 0xdc, c == 0xdd: // array 16, 32
+		n, err := mr.uint(2 << (c - 0xdc))
+		if err != nil {
+			return err
+		}
+		return mr.array(out, int(n), depth)
+	case c == 0xde, c == 0xdf: // map 16, 32
+		n, err := mr.uint(2 << (c - 0xde))
+		if err != nil {
+			return err
+		}
+		return mr.object(out, int(n), depth)
+	default: // extension types
+		return errMsgpack
+	}
+	return nil
+}
+
+// str converts a string of n bytes
+func (mr *msgpackReader) str(out *bytes.Buffer, n int) error {
+	s, err := mr.next(n)
+	if err != nil {
+		return err
+	}
+	quoted, _ := json.Marshal(string(s))
+	out.Write(quoted)
+	return nil
+}
+
+// array converts an array of n elements
+func (mr *msgpackReader) array(out *bytes.Buffer, n, depth int) error {
+	out.WriteByte('[')
+	for i := range n {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := mr.value(out, depth+1); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+// object converts a map of n pairs, whose keys must be strings
+func (mr *msgpackReader) object(out *bytes.Buffer, n, depth int) error {
+	out.WriteByte('{')
+	for i := range n {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		b, err := mr.next(1)
+		if err != nil {
+			return err
+		}
+		switch c := b[0]; {
+		case c&0xe0 == 0xa0:
+			err = mr.str(out, int(c&0x1f))
+		case c >= 0xd9 && c <= 0xdb:
+			var size uint64
+			if size, err = mr.uint(1 << (c - 0xd9)); err == nil {
+				err = mr.str(out, int(size))
+			}
+		default:
+			err = errMsgpack
+		}
+		if err != nil {
+			return err
+		}
+		out.WriteByte(':')
+		if err := mr.value(out, depth+1); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// unhex decodes s, which may contain spaces
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMsgpackToJSONScalars(t *testing.T) {
+	tests := []struct {
+		msgpack string
+		json    string
+	}{
+		{"00", "0"},
+		{"7f", "127"},
+		{"ff", "-1"},
+		{"e0", "-32"},
+		{"cc ff", "255"},
+		{"cd ffff", "65535"},
+		{"ce ffffffff", "4294967295"},
+		{"cf ffffffffffffffff", "18446744073709551615"},
+		{"d0 7f", "127"},
+		{"d0 80", "-128"},
+		{"d0 ff", "-1"},
+		{"d1 7fff", "32767"},
+		{"d1 8000", "-32768"},
+		{"d2 7fffffff", "2147483647"},
+		{"d2 80000000", "-2147483648"},
+		{"d3 7fffffffffffffff", "9223372036854775807"},
+		{"d3 8000000000000000", "-9223372036854775808"},
+		{"ca 3fc00000", "1.5"},
+		{"cb 3ff8000000000000", "1.5"},
+		{"c0", "null"},
+		{"c2", "false"},
+		{"c3", "true"},
+		{"a3 616263", `"abc"`},
+		{"d9 03 616263", `"abc"`},
+		{"da 0003 616263", `"abc"`},
+		{"db 00000003 616263", `"abc"`},
+		{"a1 22", `"\""`},
+		{"c4 03 010203", `"AQID"`},
+		{"c5 0003 010203", `"AQID"`},
+		{"c6 00000003 010203", `"AQID"`},
+		{"92 01 02", "[1,2]"},
+		{"dc 0002 01 02", "[1,2]"},
+		{"dd 00000002 01 02", "[1,2]"},
+		{"81 a1 61 01", `{"a":1}`},
+		{"de 0001 a1 61 01", `{"a":1}`},
+		{"df 00000001 a1 61 01", `{"a":1}`},
+		{"81 d9 01 61 01", `{"a":1}`},
+	}
+	for _, tt := range tests {
+		got, err := msgpackToJSON(unhex(t, tt.msgpack))
+		if err != nil || string(got) != tt.json {
+			t.Errorf("msgpackToJSON(%s) = %s, %v, want %s", tt.msgpack, got, err, tt.json)
+		}
+	}
+}
+
+func TestJSONToMsgpackWidths(t *testing.T) {
+	tests := []struct {
+		json    string
+		msgpack string
+	}{
+		{"0", "00"},
+		{"127", "7f"},
+		{"-1", "ff"},
+		{"-32", "e0"},
+		{"-33", "d0 df"},
+		{"-128", "d0 80"},
+		{"128", "d1 0080"},
+		{"-32768", "d1 8000"},
+		{"32768", "d2 00008000"},
+		{"-2147483648", "d2 80000000"},
+		{"2147483648", "d3 0000000080000000"},
+		{"-9223372036854775808", "d3 8000000000000000"},
+		{"18446744073709551615", "cf ffffffffffffffff"},
+		{"1.5", "cb 3ff8000000000000"},
+		{`"abc"`, "a3 616263"},
+		{"[]", "90"},
+		{"{}", "80"},
+		{`{"b":[true,null]}`, "81 a1 62 92 c3 c0"},
+	}
+	for _, tt := range tests {
+		got, err := jsonToMsgpack([]byte(tt.json))
+		if want := unhex(t, tt.msgpack); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("jsonToMsgpack(%s) = % x, %v, want % x", tt.json, got, err, want)
+		}
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	array := func(n int) string {
+		return "[" + strings.TrimSuffix(strings.Repeat("1,", n), ",") + "]"
+	}
+	object := func(n int) string {
+		members := make([]string, n)
+		for i := range members {
+			members[i] = fmt.Sprintf(`"k%d":%d`, i, i)
+		}
+		return "{" + strings.Join(members, ",") + "}"
+	}
+	str := func(n int) string { return `"` + strings.Repeat("x", n) + `"` }
+
+	tests := []struct {
+		name   string
+		json   string
+		header byte // the first byte of the encoding
+	}{
+		{"fixstr", str(31), 0xbf},
+		{"str 8", str(32), 0xd9},
+		{"str 8 max", str(255), 0xd9},
+		{"str 16", str(256), 0xda},
+		{"str 16 max", str(65535), 0xda},
+		{"str 32", str(65536), 0xdb},
+		{"fixarray", array(15), 0x9f},
+		{"array 16", array(16), 0xdc},
+		{"array 32", array(65536), 0xdd},
+		{"fixmap", object(15), 0x8f},
+		{"map 16", object(16), 0xde},
+		{"map 32", object(65536), 0xdf},
+		{"nested", `{"a":[1,-200,70000,-5000000000,1.25,"é",{"b":null}],"c":false}`, 0x82},
+	}
+	for _, tt := range tests {
+		packed, err := jsonToMsgpack([]byte(tt.json))
+		if err != nil {
+			t.Errorf("%s: jsonToMsgpack: %v", tt.name, err)
+			continue
+		}
+		if packed[0] != tt.header {
+			t.Errorf("%s: encoded with header %#x, want %#x", tt.name, packed[0], tt.header)
+		}
+		got, err := msgpackToJSON(packed)
+		if err != nil || string(got) != tt.json {
+			t.Errorf("%s: round trip = %.80s, %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestMsgpackToJSONRejects(t *testing.T) {
+	doc, err := jsonToMsgpack([]byte(`{"key":"value","list":[1,300,70000,5000000000,1.5,"s"],"bin":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := range len(doc) {
+		if got, err := msgpackToJSON(doc[:n]); err == nil {
+			t.Errorf("input truncated to %d bytes converted to %s", n, got)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		msgpack []byte
+	}{
+		{"empty", nil},
+		{"trailing bytes", unhex(t, "01 02")},
+		{"truncated str 32 length", unhex(t, "db 0000")},
+		{"str 32 longer than the input", unhex(t, "db 7fffffff 61")},
+		{"bin 32 longer than the input", unhex(t, "c6 ffffffff 00")},
+		{"array 32 longer than the input", unhex(t, "dd ffffffff 01")},
+		{"map 32 longer than the input", unhex(t, "df ffffffff a1 61 01")},
+		{"bin 8 shorter than its length", unhex(t, "c4 03 0102")},
+		{"integer map key", unhex(t, "81 01 02")},
+		{"map key without value", unhex(t, "81 a1 61")},
+		{"fixext", unhex(t, "d4 01 00")},
+		{"ext 8", unhex(t, "c7 01 01 00")},
+		{"never used", unhex(t, "c1")},
+		{"NaN", unhex(t, "cb 7ff8000000000001")},
+		{"infinity", unhex(t, "ca 7f800000")},
+	}
+	for _, tt := range tests {
+		if got, err := msgpackToJSON(tt.msgpack); err == nil {
+			t.Errorf("%s: converted to %s", tt.name, got)
+		}
+	}
+}
+
+func TestMsgpackDepthLimit(t *testing.T) {
+	// nested returns n single-element arrays around an empty one, which
+	// sits at depth n
+	nested := func(n int) []byte {
+		return append(bytes.Repeat([]byte{0x91}, n), 0x90)
+	}
+	if _, err := msgpackToJSON(nested(maxMsgpackDepth)); err != nil {
+		t.Errorf("nesting at the depth limit rejected: %v", err)
+	}
+	if _, err := msgpackToJSON(nested(maxMsgpackDepth + 1)); err == nil {
+		t.Error("nesting past the depth limit accepted")
+	}
+
+	var doc []byte
+	for range maxMsgpackDepth + 1 {
+		doc = append(doc, 0x81, 0xa1, 'k')
+	}
+	doc = append(doc, 0xc0)
+	if _, err := msgpackToJSON(doc); err == nil {
+		t.Error("maps nested past the depth limit accepted")
+	}
+}
+
+func TestJSONToMsgpackRejectsInvalidJSON(t *testing.T) {
+	for _, doc := range []string{"", "{", `{"a":}`, "[1,", "nul"} {
+		if got, err := jsonToMsgpack([]byte(doc)); err == nil {
+			t.Errorf("jsonToMsgpack(%q) = % x", doc, got)
+		}
+	}
+}
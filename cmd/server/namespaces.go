@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NithinkumarHV/LRU/lru"
+)
+
+// defaultNamespace is the name of the cache served by the top-level routes
+const defaultNamespace = "default"
+
+// errNamespaceExists is returned when creating a cache under a name that
+// is already taken
+var errNamespaceExists = errors.New("namespace already exists")
+
+// Registry holds the named caches served by the process
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]*Cache
+}
+
+// NewRegistry initializes an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]*Cache)}
+}
+
+// policies maps the eviction policy names accepted in configuration to
+// cache options
+var policies = map[string]lru.Option{
+	"lru":     lru.WithPolicy(lru.NewLRUPolicy[string]),
+	"lfu":     lru.WithPolicy(lru.NewLFUPolicy[string]),
+	"arc":     lru.WithPolicy(lru.NewARCPolicy[string]),
+	"2q":      lru.WithPolicy(lru.NewTwoQPolicy[string]),
+	"slru":    lru.WithPolicy(lru.NewSLRUPolicy[string]),
+	"tinylfu": lru.WithPolicy(lru.NewTinyLFUPolicy[string]),
+	"clock":   lru.WithPolicy(lru.NewClockPolicy[string]),
+	"fifo":    lru.WithPolicy(lru.NewFIFOPolicy[string]),
+	"random":  lru.WithPolicy(lru.NewRandomPolicy[string]),
+}
+
+// hotKeys is the number of most read keys tracked for /stats/hotkeys
+const hotKeys = 10
+
+// Create adds a new cache under name with the given capacity and
+// expiration time
+func (reg *Registry) Create(name string, capacity, expireSec int, opts ...lru.Option) (*Cache, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, found := reg.caches[name]; found {
+		return nil, errNamespaceExists
+	}
+	cache := newCache(capacity, expireSec, opts...)
+	reg.caches[name] = cache
+	return cache, nil
+}
+
+// newCache returns a cache with the given capacity and expiration time
+// that tracks its hot keys
+func newCache(capacity, expireSec int, opts ...lru.Option) *Cache {
+	opts = append([]lru.Option{lru.WithHotKeys(hotKeys)}, opts...)
+	return lru.NewLRUCache[string, json.RawMessage](capacity, expireSec, opts...)
+}
+
+// Get returns the cache registered under name
+func (reg *Registry) Get(name string) (*Cache, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	cache, found := reg.caches[name]
+	return cache, found
+}
+
+// Names returns the registered cache names in sorted order
+func (reg *Registry) Names() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	names := make([]string, 0, len(reg.caches))
+	for name := range reg.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handle adapts a per-cache handler constructor to serve the cache named
+// by the {name} path segment, responding 404 for unknown names
+func (reg *Registry) Handle(handler func(*Cache) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cache, found := reg.Get(r.PathValue("name"))
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		handler(cache)(w, r)
+	}
+}
+
+// namespaceRequest is the JSON body accepted by CreateCacheHandler and the
+// entry format of the namespaces file. TTL is the default expiration time
+// in seconds, with 0 meaning entries never expire. Policy names the eviction policy and defaults to "lru".
+// MaxBytes optionally bounds the estimated memory held by the cache, and
+// CleanupInterval sets the seconds between sweeps for expired entries.
+// Sliding makes reads renew an entry's TTL, and MaxIdle expires entries
+// left unread for that many seconds.
+type namespaceRequest struct {
+	Name            string `json:"name"`
+	Capacity        int    `json:"capacity"`
+	TTL             int    `json:"ttl"`
+	Policy          string `json:"policy,omitempty"`
+	MaxBytes        int64  `json:"max_bytes,omitempty"`
+	CleanupInterval int    `json:"cleanup_interval,omitempty"`
+	Sliding         bool   `json:"sliding,omitempty"`
+	MaxIdle         int    `json:"max_idle,omitempty"`
+}
+
+// valid reports whether req describes a cache that can be created
+func (req namespaceRequest) valid() bool {
+	_, known := policies[req.Policy]
+	return req.Name != "" && req.Capacity > 0 && req.TTL >= 0 &&
+		req.MaxBytes >= 0 && req.CleanupInterval >= 0 && req.MaxIdle >= 0 &&
+		(req.Policy == "" || known)
+}
+
+// create adds the cache described by req to reg
+func (req namespaceRequest) create(reg *Registry) (*Cache, error) {
+	return reg.Create(req.Name, req.Capacity, req.TTL, req.options()...)
+}
+
+// options returns the cache options described by req
+func (req namespaceRequest) options() []lru.Option {
+	var opts []lru.Option
+	if req.Policy != "" {
+		opts = append(opts, policies[req.Policy])
+	}
+	if req.MaxBytes > 0 {
+		opts = append(opts, lru.WithMaxBytes(req.MaxBytes))
+	}
+	if req.CleanupInterval > 0 {
+		opts = append(opts, lru.WithCleanupInterval(time.Duration(req.CleanupInterval)*time.Second))
+	}
+	if req.Sliding {
+		opts = append(opts, lru.WithSlidingExpiration())
+	}
+	if req.MaxIdle > 0 {
+		opts = append(opts, lru.WithMaxIdle(time.Duration(req.MaxIdle)*time.Second))
+	}
+	return opts
+}
+
+// validateAll checks that reqs describe caches that can be created, naming
+// source in errors
+func validateAll(source string, reqs []namespaceRequest) error {
+	for _, req := range reqs {
+		if !req.valid() {
+			return fmt.Errorf("%s: invalid cache %q: capacity must be positive, ttl, max_bytes, cleanup_interval and max_idle must not be negative and policy must be known", source, req.Name)
+		}
+	}
+	return nil
+}
+
+// readNamespaces returns the caches listed in the JSON file at path, which
+// holds an object of the form {"caches": [{"name", "capacity", "ttl",
+// "policy", "max_bytes", "cleanup_interval", "sliding", "max_idle"}]}
+func readNamespaces(path string) ([]namespaceRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Caches []namespaceRequest `json:"caches"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return file.Caches, validateAll(path, file.Caches)
+}
+
+// Configure creates the caches described by reqs, which must be valid.
+// Caches that already exist are resized and given the new default
+// expiration time instead; their other settings cannot change without
+// restarting. reg stays locked throughout, so the changes cannot fail
+// partway through on a cache created concurrently.
+func (reg *Registry) Configure(reqs []namespaceRequest) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for _, req := range reqs {
+		if cache, found := reg.caches[req.Name]; found {
+			cache.Resize(req.Capacity)
+			ttl := lru.NoExpiration
+			if req.TTL > 0 {
+				ttl = time.Duration(req.TTL) * time.Second
+			}
+			cache.SetDefaultTTL(ttl)
+			continue
+		}
+		reg.caches[req.Name] = newCache(req.Capacity, req.TTL, req.options()...)
+	}
+}
+
+// namespaceResponse describes a cache in ListCachesHandler responses
+type namespaceResponse struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Len      int    `json:"len"`
+}
+
+// CreateCacheHandler handles POST requests that create a named cache
+func CreateCacheHandler(reg *Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req namespaceRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || !req.valid() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if _, err := req.create(reg); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// ListCachesHandler handles GET requests listing the named caches
+func ListCachesHandler(reg *Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caches := []namespaceResponse{}
+		for _, name := range reg.Names() {
+			if cache, found := reg.Get(name); found {
+				caches = append(caches, namespaceResponse{name, cache.Cap(), cache.Len()})
+			}
+		}
+
+		response := map[string][]namespaceResponse{"caches": caches}
+		json.NewEncoder(w).Encode(response)
+	}
+}
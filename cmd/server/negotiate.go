@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxConvertedBody bounds the size of a request body converted to JSON
+const maxConvertedBody = 32 << 20
+
+// codec converts bodies between JSON and another media type
+type codec struct {
+	mediaType string
+	fromJSON  func(data []byte) ([]byte, error)
+	toJSON    func(data []byte) ([]byte, error)
+}
+
+// codecs are the media types clients may use instead of JSON
+var codecs = []codec{
+	{msgpackType, jsonToMsgpack, msgpackToJSON},
+	{protobufType, jsonToProtobuf, protobufToJSON},
+}
+
+// findCodec returns the codec of mediaType, or nil if there is none
+func findCodec(mediaType string) *codec {
+	for i := range codecs {
+		if codecs[i].mediaType == mediaType {
+			return &codecs[i]
+		}
+	}
+	return nil
+}
+
+// acceptedCodec returns the codec of the media type the Accept header of r
+// gives the highest weight, or nil if that is JSON or the header names no
+// codec. Of types with the same weight, the first listed wins.
+func acceptedCodec(r *http.Request) *codec {
+	var best *codec
+	bestWeight := 0.0
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		weight := 1.0
+		if q, found := params["q"]; found {
+			if weight, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if weight <= bestWeight {
+			continue
+		}
+		if c := findCodec(mediaType); c != nil {
+			best, bestWeight = c, weight
+		} else if mediaType == "application/json" {
+			best, bestWeight = nil, weight
+		}
+	}
+	return best
+}
+
+// codecResponseWriter buffers a response so that a JSON body can be
+// converted once complete
+type codecResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader delays the status until the body is converted
+func (cw *codecResponseWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+// Write buffers b
+func (cw *codecResponseWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	return cw.body.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *codecResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Negotiate wraps handler so that clients can use MessagePack or Protocol
+// Buffers instead of JSON on every endpoint. Request bodies sent as one of
+// codecs are converted to JSON before the handler reads them, and the JSON
+// response bodies of requests that prefer one of codecs are converted to
+// it, with an entity tag of their own. Responses whose type the handler
+// set, such as error messages, are left alone.
+func Negotiate(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if c := findCodec(mediaType); c != nil {
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConvertedBody))
+			if err == nil {
+				data, err = c.toJSON(data)
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("decoding %s body: %v", mediaType, err), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			r.ContentLength = int64(len(data))
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		w.Header().Add("Vary", "Accept")
+		c := acceptedCodec(r)
+		if c == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		cw := &codecResponseWriter{ResponseWriter: w}
+		handler.ServeHTTP(cw, r)
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+
+		h := w.Header()
+		body, converted := cw.body.Bytes(), false
+		switch {
+		case h.Get("Content-Type") != "":
+		case len(body) > 0:
+			if data, err := c.fromJSON(body); err == nil {
+				body, converted = data, true
+			}
+		case r.Method == http.MethodHead:
+			// Describe the body GET would send
+			converted = cw.status == http.StatusOK && h.Get("ETag") != ""
+		}
+		if converted {
+			h.Set("Content-Type", c.mediaType)
+			if etag := h.Get("ETag"); etag != "" {
+				h.Set("ETag", variantETag(etag, c.mediaType))
+			}
+		}
+		w.WriteHeader(cw.status)
+		w.Write(body)
+	})
+}
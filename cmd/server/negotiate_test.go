@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedCodec(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"application/json", ""},
+		{"*/*", ""},
+		{"application/msgpack", msgpackType},
+		{"application/x-protobuf", protobufType},
+		{"application/json, application/msgpack", ""},
+		{"application/msgpack, application/json", msgpackType},
+		{"application/json;q=0.5, application/x-protobuf", protobufType},
+		{"application/msgpack;q=0.5, application/x-protobuf;q=0.8", protobufType},
+		{"application/msgpack;q=0", ""},
+		{"application/msgpack;q=bad", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		got := ""
+		if c := acceptedCodec(r); c != nil {
+			got = c.mediaType
+		}
+		if got != tt.want {
+			t.Errorf("acceptedCodec(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+// etagHandler serves body as JSON with an entity tag, as GetHandler does
+func etagHandler(body string) http.Handler {
+	etag := valueETag([]byte(body))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if notModified(w, r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method != http.MethodHead {
+			io.WriteString(w, body)
+		}
+	})
+}
+
+func TestNegotiateETag(t *testing.T) {
+	body := `{"value":"` + strings.Repeat("x", gzipMinSize) + `"}`
+	etag := valueETag([]byte(body))
+	msgpackETag := variantETag(etag, msgpackType)
+	handler := Gzip(Negotiate(etagHandler(body)))
+
+	tests := []struct {
+		name, method, accept, acceptEncoding, ifNoneMatch string
+		status                                            int
+		etag, contentType                                 string
+	}{
+		{"json", "GET", "", "", "", 200, etag, ""},
+		{"msgpack", "GET", msgpackType, "", "", 200, msgpackETag, msgpackType},
+		{"protobuf", "GET", protobufType, "", "", 200, variantETag(etag, protobufType), protobufType},
+		{"msgpack gzip", "GET", msgpackType, "gzip", "", 200, variantETag(msgpackETag, "gzip"), msgpackType},
+		{"msgpack head", "HEAD", msgpackType, "", "", 200, msgpackETag, msgpackType},
+		{"msgpack revalidated", "GET", msgpackType, "", msgpackETag, 304, msgpackETag, ""},
+		{"msgpack gzip revalidated", "GET", msgpackType, "gzip", variantETag(msgpackETag, "gzip"), 304, variantETag(msgpackETag, "gzip"), ""},
+		{"json revalidated", "GET", "", "", etag, 304, etag, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		h := w.Header()
+		if w.Code != tt.status || h.Get("ETag") != tt.etag || tt.contentType != "" && h.Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: status %d, ETag %s, Content-Type %s; want %d, %s, %s",
+				tt.name, w.Code, h.Get("ETag"), h.Get("Content-Type"), tt.status, tt.etag, tt.contentType)
+		}
+		if !slices.Contains(h.Values("Vary"), "Accept") {
+			t.Errorf("%s: Vary %q does not name Accept", tt.name, h.Values("Vary"))
+		}
+	}
+}
+
+func TestNegotiateRequestBody(t *testing.T) {
+	var received string
+	handler := Negotiate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = r.Header.Get("Content-Type") + " " + string(data)
+	}))
+
+	doc := `{"key":"k","value":[1,"two"]}`
+	for _, c := range codecs {
+		body, err := c.fromJSON([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", c.mediaType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if want := "application/json " + doc; received != want {
+			t.Errorf("%s body received as %q, want %q", c.mediaType, received, want)
+		}
+
+		r = httptest.NewRequest(http.MethodPut, "/", strings.NewReader("\xff\xff"))
+		r.Header.Set("Content-Type", c.mediaType)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("invalid %s body: status %d, want 400", c.mediaType, w.Code)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// routeDoc describes an endpoint in the OpenAPI document
+type routeDoc struct {
+	summary string
+	query   []string // query parameters
+	body    any      // a value of the request body's type, if it has one
+}
+
+// routeDocs describes the endpoints by their pattern, that of per-cache
+// routes being the one at the top level
+var routeDocs = map[string]routeDoc{
+	"GET /cache/{key}":    {summary: "Get the value of a key, reading misses through the origin if one is configured"},
+	"PUT /cache/{key}":    {summary: "Store a value under a key", body: SetRequest{}},
+	"DELETE /cache/{key}": {summary: "Remove a key"},
+	"HEAD /cache/{key}":   {summary: "Check whether a key is cached"},
+	"POST /getdel":        {summary: "Get the value of a key and remove it", query: []string{"key"}},
+	"GET /info":           {summary: "Get the value of a key with its metadata", query: []string{"key"}},
+	"GET /ttl":            {summary: "Get the remaining lifetime of a key in seconds, -1 if it never expires", query: []string{"key"}},
+	"POST /touch":         {summary: "Reset a key's expiration to the cache default", query: []string{"key"}},
+	"POST /expire":        {summary: "Set a key's TTL in seconds", query: []string{"key", "ttl"}},
+	"POST /persist":       {summary: "Remove a key's expiration", query: []string{"key"}},
+	"GET /keys":           {summary: "Page through the keys with their TTL and size", query: []string{"cursor", "count", "pattern"}},
+	"GET /scan":           {summary: "Page through the keys", query: []string{"cursor", "count"}},
+	"GET /mget":           {summary: "Get several keys, given as repeated key parameters", query: []string{"key"}},
+	"POST /mset":          {summary: "Set several keys", body: []SetRequest{}},
+	"POST /pipeline":      {summary: "Run get, set and delete operations in order", body: []pipelineOp{}},
+	"GET /stats":          {summary: "Get the cache's counters"},
+	"GET /stats/hotkeys":  {summary: "Get the most read keys"},
+	"POST /stats/reset":   {summary: "Zero the cache's counters"},
+	"POST /flush":         {summary: "Remove every entry"},
+	"PUT /admin/capacity": {summary: "Resize the cache", body: struct {
+		Capacity int `json:"capacity"`
+	}{}},
+	"PUT /admin/ttl": {summary: "Change the default TTL in seconds", body: struct {
+		TTL int `json:"ttl"`
+	}{}},
+	"GET /caches":        {summary: "List the named caches"},
+	"POST /caches":       {summary: "Create a named cache", body: namespaceRequest{}},
+	"POST /admin/reload": {summary: "Reload the configuration"},
+	"GET /healthz":       {summary: "Liveness probe"},
+	"GET /readyz":        {summary: "Readiness probe"},
+	"GET /openapi.json":  {summary: "This document"},
+	"GET /metrics":       {summary: "Cache counters and request latencies in the Prometheus text format"},
+}
+
+// globalRoute is an endpoint that is not per-cache, as listed in the
+// OpenAPI document; scope is 0 for endpoints open to everyone
+type globalRoute struct {
+	method    string
+	path      string
+	scope     scope
+	versioned bool // whether it is served under apiVersion
+}
+
+// globalRoutes lists the endpoints that are not per-cache
+var globalRoutes = []globalRoute{
+	{http.MethodGet, "/caches", scopeRead, true},
+	{http.MethodPost, "/caches", scopeAdmin, true},
+	{http.MethodPost, "/admin/reload", scopeAdmin, true},
+	{http.MethodGet, "/healthz", 0, false},
+	{http.MethodGet, "/readyz", 0, false},
+	{http.MethodGet, "/openapi.json", 0, false},
+	{http.MethodGet, "/metrics", scopeRead, false},
+}
+
+// pathParam matches the wildcards of a ServeMux pattern
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// scopeNames maps scopes to their names in configuration
+var scopeNames = map[scope]string{scopeRead: "read", scopeWrite: "write", scopeAdmin: "admin"}
+
+// openAPI returns the OpenAPI 3 document describing routes, mounted at
+// apiVersion and under it at /caches/{name}, and globalRoutes. The
+// unversioned aliases and the legacy routes are left out.
+func openAPI() map[string]any {
+	paths := map[string]map[string]any{}
+	add := func(method, path string, s scope, doc routeDoc) {
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = operation(method, path, s, doc)
+	}
+	for _, rt := range routes {
+		doc := routeDocs[rt.pattern("")]
+		add(rt.method, apiVersion+rt.path, rt.scope, doc)
+		add(rt.method, apiVersion+"/caches/{name}"+rt.path, rt.scope, doc)
+	}
+	for _, rt := range globalRoutes {
+		path := rt.path
+		if rt.versioned {
+			path = apiVersion + path
+		}
+		add(rt.method, path, rt.scope, routeDocs[rt.method+" "+rt.path])
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "LRU cache server",
+			"version":     "1.0.0",
+			"description": "Values are arbitrary JSON documents. Requests must carry an API key or bearer token of the listed scope only when the server is configured with them. The " + apiVersion + " endpoints are also served without the prefix, for older clients.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// operation returns the OpenAPI operation for method on path
+func operation(method, path string, s scope, doc routeDoc) map[string]any {
+	var params []map[string]any
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{
+			"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, name := range doc.query {
+		params = append(params, map[string]any{
+			"name": name, "in": "query", "schema": map[string]any{"type": "string"},
+		})
+	}
+
+	op := map[string]any{
+		"summary":   doc.summary,
+		"responses": map[string]any{"default": map[string]any{"description": "JSON response; see the status code"}},
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if doc.body != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(doc.body))},
+			},
+		}
+	}
+	if s != 0 {
+		op["security"] = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+		op["x-scope"] = scopeNames[s]
+	}
+	return op
+}
+
+// jsonSchema returns the JSON schema of the values of t as encoding/json
+// encodes them
+func jsonSchema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeFor[json.RawMessage]():
+		return map[string]any{}
+	case reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		addFields(t, properties)
+		return map[string]any{"type": "object", "properties": properties}
+	}
+	return map[string]any{}
+}
+
+// addFields adds the JSON properties of struct type t to properties,
+// including those of embedded structs
+func addFields(t reflect.Type, properties map[string]any) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !field.IsExported() && !field.Anonymous:
+			continue
+		case field.Anonymous && name == "":
+			addFields(field.Type, properties)
+			continue
+		case name == "":
+			name = field.Name
+		}
+		properties[name] = jsonSchema(field.Type)
+	}
+}
+
+// OpenAPIHandler handles GET requests for the OpenAPI document
+func OpenAPIHandler() http.HandlerFunc {
+	spec, _ := json.Marshal(openAPI())
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// origin is the base URL that GetHandler reads missing keys through, or
+// empty to answer misses with 404. It is set by the origin setting.
+var origin string
+
+// originClient fetches values from the origin
+var originClient = &http.Client{Timeout: 10 * time.Second}
+
+// maxOriginBody bounds the size of a value read from the origin
+const maxOriginBody = 1 << 20
+
+// errOriginMiss is returned by fetch when the origin has no value for a key
+var errOriginMiss = errors.New("origin has no value for key")
+
+// fetch loads the value of key from GET {origin}/{key} in a client span
+// of the trace of ctx, if it has one, which the request propagates. A 404
+// yields errOriginMiss; any other failure, or a body that is not JSON, is
+// an error. GetHandler calls it through Cache.GetOrLoad, so concurrent
+// misses on the same key share a single request to the origin.
+func fetch(ctx context.Context, key string) (_ json.RawMessage, err error) {
+	ctx, sp := startSpan(ctx, "GET", spanClient)
+	defer func() {
+		if err != nil && err != errOriginMiss {
+			sp.fail(err)
+		}
+		sp.finish()
+	}()
+
+	u := strings.TrimSuffix(origin, "/") + "/" + url.PathEscape(key)
+	sp.set("http.request.method", http.MethodGet)
+	sp.set("url.full", u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if sp != nil {
+		req.Header.Set("traceparent", sp.traceparent())
+	}
+	resp, err := originClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	sp.set("http.response.status_code", resp.StatusCode)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errOriginMiss
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("origin: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOriginBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxOriginBody {
+		return nil, fmt.Errorf("origin: value of %q exceeds %d bytes", key, maxOriginBody)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("origin: value of %q is not JSON", key)
+	}
+	return body, nil
+}
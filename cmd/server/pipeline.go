@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/NithinkumarHV/LRU/lru"
+)
+
+// maxPipelineOps bounds the number of operations in one pipeline request
+const maxPipelineOps = 1000
+
+// pipelineOp is an operation in a PipelineHandler request. Op is "get",
+// "set" or "delete"; a set takes the remaining fields of a SetRequest.
+type pipelineOp struct {
+	Op string `json:"op"`
+	SetRequest
+}
+
+// pipelineResult is the outcome of a pipelineOp. Status is the code the
+// equivalent /cache/{key} request would have returned.
+type pipelineResult struct {
+	Status   int             `json:"status"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Negative bool            `json:"negative,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// PipelineHandler handles POST requests that run a list of get, set and
+// delete operations in order, given as a JSON array of pipelineOps, and
+// respond with a result for each. A failed operation does not stop the
+// ones after it.
+func PipelineHandler(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ops []pipelineOp
+		err := json.NewDecoder(r.Body).Decode(&ops)
+		if err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(ops) > maxPipelineOps {
+			http.Error(w, fmt.Sprintf("more than %d operations", maxPipelineOps), http.StatusBadRequest)
+			return
+		}
+
+		results := make([]pipelineResult, len(ops))
+		for i, op := range ops {
+			results[i] = op.run(r.Context(), cache)
+		}
+		response := map[string][]pipelineResult{"results": results}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// run performs op on cache, tracing gets in the trace of ctx
+func (op pipelineOp) run(ctx context.Context, cache *Cache) pipelineResult {
+	if op.Key == "" {
+		return pipelineResult{Status: http.StatusBadRequest, Error: errMissingKey.Error()}
+	}
+
+	switch op.Op {
+	case "get":
+		value, result, err := lookup(ctx, cache, op.Key)
+		switch {
+		case err != nil:
+			return pipelineResult{Status: http.StatusBadGateway, Error: err.Error()}
+		case result == lru.Miss:
+			return pipelineResult{Status: http.StatusNotFound}
+		case result == lru.NegativeHit:
+			return pipelineResult{Status: http.StatusNotFound, Negative: true}
+		}
+		return pipelineResult{Status: http.StatusOK, Value: value}
+	case "set":
+		if err := op.validate(); err != nil {
+			return pipelineResult{Status: http.StatusBadRequest, Error: err.Error()}
+		}
+		return pipelineResult{Status: store(cache, op.SetRequest)}
+	case "delete":
+		if !cache.Delete(op.Key) {
+			return pipelineResult{Status: http.StatusNotFound}
+		}
+		return pipelineResult{Status: http.StatusNoContent}
+	}
+	return pipelineResult{Status: http.StatusBadRequest, Error: fmt.Sprintf("unknown op %q", op.Op)}
+}
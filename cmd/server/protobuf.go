@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// protobufType is the media type of Protocol Buffers bodies
+const protobufType = "application/x-protobuf"
+
+// maxProtobufDepth bounds the nesting of Protocol Buffers structs and
+// lists
+const maxProtobufDepth = 1000
+
+// errProtobuf is returned for Protocol Buffers input that cannot be
+// converted to JSON
+var errProtobuf = errors.New("invalid or unsupported Protocol Buffers message")
+
+// Field numbers of the google.protobuf.Value message, whose oneof holds
+// one of the kinds of JSON value, as defined in struct.proto. Struct and
+// ListValue hold their fields and values in field 1, and the map entries
+// of a Struct hold the key in field 1 and the value in field 2.
+const (
+	protobufNull   = 1 // NullValue enum, always 0
+	protobufNumber = 2 // double
+	protobufString = 3
+	protobufBool   = 4
+	protobufStruct = 5
+	protobufList   = 6
+)
+
+// protobufKinds maps the fields of the oneof of Value to their wire types
+var protobufKinds = map[uint64]int{
+	protobufNull:   wireVarint,
+	protobufNumber: wireFixed64,
+	protobufString: wireBytes,
+	protobufBool:   wireVarint,
+	protobufStruct: wireBytes,
+	protobufList:   wireBytes,
+}
+
+// Protocol Buffers wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// jsonToProtobuf converts the JSON document data to a google.protobuf.Value
+// message, the well-known type that holds any JSON value, keeping the
+// order of object members. Numbers are encoded as doubles, as Value
+// requires, so integers beyond 2^53 lose precision.
+func jsonToProtobuf(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var out []byte
+	if err := encodeProtobuf(dec, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// encodeProtobuf appends the next JSON value read from dec to out as the
+// fields of a Value
+func encodeProtobuf(dec *json.Decoder, out *[]byte) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		*out = append(appendTag(*out, protobufNull, wireVarint), 0)
+	case bool:
+		*out = appendTag(*out, protobufBool, wireVarint)
+		if tok {
+			*out = append(*out, 1)
+		} else {
+			*out = append(*out, 0)
+		}
+	case float64:
+		*out = appendTag(*out, protobufNumber, wireFixed64)
+		*out = binary.LittleEndian.AppendUint64(*out, math.Float64bits(tok))
+	case string:
+		*out = appendBytesField(*out, protobufString, []byte(tok))
+	case json.Delim:
+		var members []byte
+		for dec.More() {
+			var member []byte
+			if tok == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				var value []byte
+				if err := encodeProtobuf(dec, &value); err != nil {
+					return err
+				}
+				member = appendBytesField(member, 1, []byte(key.(string)))
+				member = appendBytesField(member, 2, value)
+			} else if err := encodeProtobuf(dec, &member); err != nil {
+				return err
+			}
+			members = appendBytesField(members, 1, member)
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		if tok == '{' {
+			*out = appendBytesField(*out, protobufStruct, members)
+		} else {
+			*out = appendBytesField(*out, protobufList, members)
+		}
+	}
+	return nil
+}
+
+// appendTag appends the key of a field numbered field of wire type wire
+func appendTag(out []byte, field, wire int) []byte {
+	return binary.AppendUvarint(out, uint64(field<<3|wire))
+}
+
+// appendBytesField appends a length-delimited field holding b
+func appendBytesField(out []byte, field int, b []byte) []byte {
+	out = appendTag(out, field, wireBytes)
+	out = binary.AppendUvarint(out, uint64(len(b)))
+	return append(out, b...)
+}
+
+// protobufField is a field of a Protocol Buffers message
+type protobufField struct {
+	num  uint64
+	wire int
+	n    uint64 // value of a varint or fixed-size field
+	b    []byte // contents of a length-delimited field
+}
+
+// protobufFields calls f for each field of the message data. Groups, a
+// deprecated wire type, are rejected.
+func protobufFields(data []byte, f func(field protobufField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtobuf
+		}
+		data = data[n:]
+		field := protobufField{num: key >> 3, wire: int(key & 7)}
+		switch field.wire {
+		case wireVarint:
+			if field.n, n = binary.Uvarint(data); n <= 0 {
+				return errProtobuf
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errProtobuf
+			}
+			field.n, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errProtobuf
+			}
+			field.n, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errProtobuf
+			}
+			field.b, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return errProtobuf
+		}
+		if err := f(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protobufToJSON converts the google.protobuf.Value message data to JSON.
+// Non-finite numbers, strings that are not UTF-8 and values of no kind
+// are rejected.
+func protobufToJSON(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if err := protobufValue(&out, data, 0); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// protobufValue converts the Value message data to JSON, written to out.
+// Unknown fields are skipped and, as for any oneof, the last kind set
+// wins.
+func protobufValue(out *bytes.Buffer, data []byte, depth int) error {
+	if depth > maxProtobufDepth {
+		return errProtobuf
+	}
+	var kind *protobufField
+	err := protobufFields(data, func(field protobufField) error {
+		if wire, known := protobufKinds[field.num]; known {
+			if field.wire != wire {
+				return errProtobuf
+			}
+			kind = &field
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if kind == nil {
+		return errProtobuf
+	}
+
+	switch kind.num {
+	case protobufNull:
+		out.WriteString("null")
+	case protobufNumber:
+		f := math.Float64frombits(kind.n)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return errProtobuf
+		}
+		out.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case protobufString:
+		return protobufStr(out, kind.b)
+	case protobufBool:
+		out.WriteString(strconv.FormatBool(kind.n != 0))
+	case protobufStruct:
+		return protobufObject(out, kind.b, depth)
+	case protobufList:
+		return protobufArray(out, kind.b, depth)
+	}
+	return nil
+}
+
+// protobufStr converts the string b
+func protobufStr(out *bytes.Buffer, b []byte) error {
+	if !utf8.Valid(b) {
+		return errProtobuf
+	}
+	quoted, _ := json.Marshal(string(b))
+	out.Write(quoted)
+	return nil
+}
+
+// protobufObject converts the Struct message data
+func protobufObject(out *bytes.Buffer, data []byte, depth int) error {
+	out.WriteByte('{')
+	n := 0
+	err := protobufFields(data, func(field protobufField) error {
+		if field.num != 1 {
+			return nil
+		}
+		if field.wire != wireBytes {
+			return errProtobuf
+		}
+		// A missing key is empty, and a missing value is a Value of no
+		// kind, which protobufValue rejects
+		var key, value []byte
+		err := protobufFields(field.b, func(entry protobufField) error {
+			switch {
+			case entry.num != 1 && entry.num != 2:
+				return nil
+			case entry.wire != wireBytes:
+				return errProtobuf
+			case entry.num == 1:
+				key = entry.b
+			default:
+				value = entry.b
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			out.WriteByte(',')
+		}
+		n++
+		if err := protobufStr(out, key); err != nil {
+			return err
+		}
+		out.WriteByte(':')
+		return protobufValue(out, value, depth+1)
+	})
+	if err != nil {
+		return err
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+// protobufArray converts the ListValue message data
+func protobufArray(out *bytes.Buffer, data []byte, depth int) error {
+	out.WriteByte('[')
+	n := 0
+	err := protobufFields(data, func(field protobufField) error {
+		if field.num != 1 {
+			return nil
+		}
+		if field.wire != wireBytes {
+			return errProtobuf
+		}
+		if n > 0 {
+			out.WriteByte(',')
+		}
+		n++
+		return protobufValue(out, field.b, depth+1)
+	})
+	if err != nil {
+		return err
+	}
+	out.WriteByte(']')
+	return nil
+}
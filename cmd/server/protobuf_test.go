@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJSONToProtobuf(t *testing.T) {
+	tests := []struct {
+		json     string
+		protobuf string
+	}{
+		{"null", "08 00"},
+		{"1.5", "11 000000000000f83f"},
+		{"-2", "11 00000000000000c0"},
+		{`"abc"`, "1a 03 616263"},
+		{"true", "20 01"},
+		{"false", "20 00"},
+		{"[]", "32 00"},
+		{"{}", "2a 00"},
+		{"[true]", "32 04 0a02 2001"},
+		{`{"a":1}`, "2a 10 0a0e 0a0161 1209 11000000000000f03f"},
+	}
+	for _, tt := range tests {
+		got, err := jsonToProtobuf([]byte(tt.json))
+		if err != nil {
+			t.Errorf("jsonToProtobuf(%s): %v", tt.json, err)
+			continue
+		}
+		if want := unhex(t, tt.protobuf); !bytes.Equal(got, want) {
+			t.Errorf("jsonToProtobuf(%s) = % x, want % x", tt.json, got, want)
+		}
+	}
+}
+
+func TestProtobufToJSON(t *testing.T) {
+	tests := []struct {
+		protobuf string
+		json     string
+	}{
+		{"08 00", "null"},
+		{"11 000000000000f83f", "1.5"},
+		{"1a 03 616263", `"abc"`},
+		{"1a 01 22", `"\""`},
+		{"20 01", "true"},
+		{"20 02", "true"},
+		{"32 00", "[]"},
+		{"2a 00", "{}"},
+		{"2a 10 0a0e 0a0161 1209 11000000000000f03f", `{"a":1}`},
+		{"2a 0b 0a09 1207 1a05 656d707479", `{"":"empty"}`},
+		{"08 00 f801 05", "null"},         // unknown field skipped
+		{"08 00 20 01", "true"},           // the last kind set wins
+		{"2a 04 1a02 6869 20 01", "true"}, // even over a struct
+	}
+	for _, tt := range tests {
+		got, err := protobufToJSON(unhex(t, tt.protobuf))
+		if err != nil {
+			t.Errorf("protobufToJSON(%s): %v", tt.protobuf, err)
+			continue
+		}
+		if string(got) != tt.json {
+			t.Errorf("protobufToJSON(%s) = %s, want %s", tt.protobuf, got, tt.json)
+		}
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	doc := `{"key":"k","value":{"n":[1,-2.5,1e+300,"s",null,true,{}],"nested":{"deep":[[],{"x":false}]},"":"é"},"ttl":60}`
+	data, err := jsonToProtobuf([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := protobufToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(back) != doc {
+		t.Errorf("round trip = %s, want %s", back, doc)
+	}
+}
+
+func TestProtobufToJSONRejects(t *testing.T) {
+	tests := []string{
+		"",                    // no kind
+		"f801 05",             // only an unknown field
+		"10 00",               // number as a varint
+		"1a 05 6162",          // truncated string
+		"11 0000",             // truncated double
+		"11 000000000000f87f", // NaN
+		"11 000000000000f07f", // infinity
+		"1a 01 ff",            // string not UTF-8
+		"0b",                  // group
+		"80",                  // truncated key
+		"2a 05 0a03 0a0161",   // map entry without a value
+		"2a 04 0a02 1200",     // map entry value of no kind
+		"2a 02 0800",          // struct field as a varint
+		"32 02 0a00",          // list value of no kind
+	}
+	for _, tt := range tests {
+		if got, err := protobufToJSON(unhex(t, tt)); err == nil {
+			t.Errorf("protobufToJSON(%s) = %s, want an error", tt, got)
+		}
+	}
+}
+
+func TestProtobufDepthLimit(t *testing.T) {
+	nested := func(depth int) []byte {
+		data, err := jsonToProtobuf([]byte(strings.Repeat("[", depth) + strings.Repeat("]", depth)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	if _, err := protobufToJSON(nested(maxProtobufDepth)); err != nil {
+		t.Errorf("nesting %d deep: %v", maxProtobufDepth, err)
+	}
+	if _, err := protobufToJSON(nested(maxProtobufDepth + 2)); err == nil {
+		t.Errorf("nesting %d deep accepted", maxProtobufDepth+2)
+	}
+}
+
+func TestJSONToProtobufRejectsInvalidJSON(t *testing.T) {
+	for _, doc := range []string{"", "{", `{"a":}`, "[1,"} {
+		if _, err := jsonToProtobuf([]byte(doc)); err == nil {
+			t.Errorf("jsonToProtobuf(%q) succeeded", doc)
+		}
+	}
+}
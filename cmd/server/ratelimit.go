@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NithinkumarHV/LRU/lru"
+)
+
+// rateLimits is the number of clients whose token buckets are kept; the
+// least recently seen client's bucket is dropped beyond it
+const rateLimits = 10000
+
+// rateLimit configures per-client rate limiting. Each client may make RPS
+// requests per second on average and up to Burst at once, which defaults
+// to RPS rounded up. An RPS of 0 disables limiting.
+type rateLimit struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+// validate checks that l is not negative
+func (l rateLimit) validate() error {
+	if l.RPS < 0 || l.Burst < 0 {
+		return fmt.Errorf("rate limit: negative rps %v or burst %d", l.RPS, l.Burst)
+	}
+	return nil
+}
+
+// burst returns the bucket size of l
+func (l rateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Ceil(l.RPS)
+}
+
+// bucket is the token bucket of a client
+type bucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // when tokens was last refilled
+}
+
+// take removes a token from b, first refilling it for the time since the
+// last request. If b is empty it returns false and how long until a
+// token is available.
+func (b *bucket) take(l rateLimit, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(l.burst(), b.tokens+now.Sub(b.last).Seconds()*l.RPS)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.RPS * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// RateLimiter limits the requests of each client, identified by the API
+// key, bearer token or client certificate it was authenticated with, or
+// else by its IP address
+type RateLimiter struct {
+	auth    *Authenticator
+	limit   atomic.Pointer[rateLimit]
+	buckets *lru.LRUCache[string, *bucket]
+}
+
+// NewRateLimiter returns a RateLimiter applying l to the clients auth
+// identifies
+func NewRateLimiter(l rateLimit, auth *Authenticator) *RateLimiter {
+	rl := &RateLimiter{auth: auth, buckets: lru.NewLRUCache[string, *bucket](rateLimits, 0)}
+	rl.SetLimit(l)
+	return rl
+}
+
+// SetLimit replaces the limit applied from now on
+func (rl *RateLimiter) SetLimit(l rateLimit) {
+	rl.limit.Store(&l)
+}
+
+// Limit wraps handler to respond 429, with a Retry-After header in
+// seconds, to clients that exceed the limit
+func (rl *RateLimiter) Limit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := *rl.limit.Load()
+		if l.RPS == 0 {
+			handler(w, r)
+			return
+		}
+
+		r, id := rl.auth.identify(r)
+		now := time.Now()
+		b, _ := rl.buckets.GetOrSet(id.client, &bucket{tokens: l.burst(), last: now})
+		if ok, wait := b.take(l, now); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		handler(w, r)
+	}
+}
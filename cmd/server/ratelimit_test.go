@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitExhaustsBucket(t *testing.T) {
+	auth, err := NewAuthenticator([]apiKey{{Key: "a", Scope: "read"}, {Key: "b", Scope: "read"}}, jwtSettings{}, tlsSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := NewRateLimiter(rateLimit{RPS: 0.5, Burst: 2}, auth)
+	handler := rl.Limit(auth.Require(scopeRead, okHandler))
+	request := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	for i := range 2 {
+		if w := request("a"); w.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst: status %d", i, w.Code)
+		}
+	}
+	w := request("a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request beyond the burst: status %d, want 429", w.Code)
+	}
+	// A token comes back every 2s
+	if retry := w.Header().Get("Retry-After"); retry != "2" {
+		t.Errorf("Retry-After = %q, want 2", retry)
+	}
+	if w := request("b"); w.Code != http.StatusOK {
+		t.Errorf("another key limited with the first: status %d", w.Code)
+	}
+
+	// Lifting the limit takes effect at once
+	rl.SetLimit(rateLimit{})
+	if w := request("a"); w.Code != http.StatusOK {
+		t.Errorf("request after the limit was lifted: status %d", w.Code)
+	}
+}
+
+func TestBucketRefills(t *testing.T) {
+	l := rateLimit{RPS: 10, Burst: 1}
+	start := time.Now()
+	b := &bucket{tokens: l.burst(), last: start}
+	if ok, _ := b.take(l, start); !ok {
+		t.Fatal("full bucket refused a request")
+	}
+	ok, wait := b.take(l, start)
+	if ok || wait != 100*time.Millisecond {
+		t.Fatalf("take on an empty bucket = %v, %v, want false, 100ms", ok, wait)
+	}
+	if ok, _ := b.take(l, start.Add(wait)); !ok {
+		t.Error("bucket not refilled after the wait it gave")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// Reloader applies changes to the configuration while the server runs.
+// Only the API keys, the JWT settings, the scopes of client certificates,
+// the rate limit, the log level and the capacity and default expiration
+// time of configured caches are reloaded, and caches newly added to the
+// configuration are created; other settings take effect on restart.
+type Reloader struct {
+	mu    sync.Mutex // serializes reloads
+	args  []string
+	reg   *Registry
+	auth  *Authenticator
+	rate  *RateLimiter
+	level *slog.LevelVar
+}
+
+// NewReloader returns a Reloader that rebuilds the configuration from
+// the command-line arguments args, the config file and the environment,
+// and applies it to reg, auth, rate and level
+func NewReloader(args []string, reg *Registry, auth *Authenticator, rate *RateLimiter, level *slog.LevelVar) *Reloader {
+	return &Reloader{args: args, reg: reg, auth: auth, rate: rate, level: level}
+}
+
+// Reload reads the configuration again and applies it. An invalid
+// configuration is rejected whole: everything is read and validated
+// before any of it is applied.
+func (rl *Reloader) Reload() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	cfg, err := parseConfig(rl.args)
+	if err != nil {
+		return err
+	}
+	reqs, err := cfg.cacheRequests()
+	if err != nil {
+		return err
+	}
+	keys, err := cfg.apiKeys()
+	if err != nil {
+		return err
+	}
+	if _, err := keyScopes(keys); err != nil {
+		return err
+	}
+
+	rl.reg.Configure(reqs)
+	rl.auth.SetKeys(keys) // validated above
+	rl.auth.SetJWT(cfg.JWT)
+	rl.auth.SetClientScopes(cfg.TLS) // validated by parseConfig
+	rl.rate.SetLimit(cfg.RateLimit)
+	lvl, _ := cfg.Log.level() // validated by parseConfig
+	rl.level.Set(lvl)
+	return nil
+}
+
+// ReloadHandler handles POST requests that reload the configuration,
+// responding 400 with the reason if it is invalid
+func ReloadHandler(rl *Reloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := rl.Reload(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
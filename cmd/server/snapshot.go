@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/NithinkumarHV/LRU/lru"
+)
+
+// snapshotEntry is a cache entry in the snapshot file. ExpireAt is omitted
+// for entries that never expire.
+type snapshotEntry struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	ExpireAt *time.Time      `json:"expire_at,omitempty"`
+}
+
+// snapshotCache is a cache in the snapshot file, with its entries from the
+// most to the least recently used
+type snapshotCache struct {
+	Name     string          `json:"name"`
+	Capacity int             `json:"capacity"`
+	TTL      int             `json:"ttl"`
+	Entries  []snapshotEntry `json:"entries"`
+}
+
+// SaveSnapshot writes the entries of every cache in reg to the file at
+// path, replacing it atomically so that a crash mid-write leaves the
+// previous snapshot intact
+func (reg *Registry) SaveSnapshot(path string) error {
+	file := struct {
+		Caches []snapshotCache `json:"caches"`
+	}{Caches: []snapshotCache{}}
+	for _, name := range reg.Names() {
+		cache, found := reg.Get(name)
+		if !found {
+			continue
+		}
+		snap := snapshotCache{Name: name, Capacity: cache.Cap(), Entries: []snapshotEntry{}}
+		if ttl := cache.DefaultTTL(); ttl > 0 {
+			snap.TTL = int(ttl / time.Second)
+		}
+		for _, entry := range cache.Entries() {
+			e := snapshotEntry{Key: entry.Key, Value: entry.Value}
+			if !entry.ExpireAt.IsZero() {
+				e.ExpireAt = &entry.ExpireAt
+			}
+			snap.Entries = append(snap.Entries, e)
+		}
+		file.Caches = append(file.Caches, snap)
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot restores the entries saved by SaveSnapshot to the file at
+// path. Caches missing from reg are created with the saved capacity and
+// TTL and the default options. Entries that have expired since are
+// skipped. A missing file is not an error.
+func (reg *Registry) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file struct {
+		Caches []snapshotCache `json:"caches"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	now := time.Now()
+	for _, snap := range file.Caches {
+		cache, found := reg.Get(snap.Name)
+		if !found {
+			if snap.Capacity <= 0 || snap.TTL < 0 {
+				return fmt.Errorf("%s: invalid cache %q", path, snap.Name)
+			}
+			if cache, err = reg.Create(snap.Name, snap.Capacity, snap.TTL); err != nil {
+				return fmt.Errorf("%s: cache %q: %w", path, snap.Name, err)
+			}
+		}
+		// Insert the least recently used entries first so that recency is
+		// preserved
+		for _, e := range slices.Backward(snap.Entries) {
+			ttl := lru.NoExpiration
+			if e.ExpireAt != nil {
+				if ttl = e.ExpireAt.Sub(now); ttl <= 0 {
+					continue
+				}
+			}
+			cache.SetWithTTL(e.Key, e.Value, ttl)
+		}
+	}
+	return nil
+}
+
+// Close closes every cache in reg, stopping their cleanup goroutines
+func (reg *Registry) Close() {
+	for _, name := range reg.Names() {
+		if cache, found := reg.Get(name); found {
+			cache.Close()
+		}
+	}
+}
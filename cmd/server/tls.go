@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// tlsVersions maps the TLS versions accepted in configuration to their
+// crypto/tls constants
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsSettings configures HTTPS. The server speaks plain HTTP unless Cert
+// and Key name a certificate and its private key in PEM files.
+// MinVersion is "1.2", the default, or "1.3". CipherSuites restricts the
+// TLS 1.2 cipher suites by their crypto/tls names, such as
+// "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"; TLS 1.3 suites are not
+// configurable.
+//
+// ClientCA names a PEM file of CA certificates; if set, clients must
+// present a certificate signed by one of them. ClientCNs then optionally
+// limits the clients to those whose certificate has one of the listed
+// common names, and ClientScopes maps common names to the scope, "read",
+// "write" or "admin", that their certificate grants as an API key would.
+type tlsSettings struct {
+	Cert         string            `json:"cert"`
+	Key          string            `json:"key"`
+	MinVersion   string            `json:"min_version"`
+	CipherSuites []string          `json:"cipher_suites"`
+	ClientCA     string            `json:"client_ca"`
+	ClientCNs    []string          `json:"client_cns"`
+	ClientScopes map[string]string `json:"client_scopes"`
+}
+
+// enabled reports whether the server should serve HTTPS
+func (s tlsSettings) enabled() bool {
+	return s.Cert != ""
+}
+
+// validate checks that s can be turned into a tls.Config
+func (s tlsSettings) validate() error {
+	if (s.Cert == "") != (s.Key == "") {
+		return errors.New("tls: cert and key must be given together")
+	}
+	if s.ClientCA != "" && !s.enabled() {
+		return errors.New("tls: client_ca requires cert and key")
+	}
+	if len(s.ClientCNs) > 0 && s.ClientCA == "" {
+		return errors.New("tls: client_cns requires client_ca")
+	}
+	if len(s.ClientScopes) > 0 && s.ClientCA == "" {
+		return errors.New("tls: client_scopes requires client_ca")
+	}
+	for cn, name := range s.ClientScopes {
+		if _, known := scopes[name]; !known {
+			return fmt.Errorf("tls: unknown scope %q for client %q", name, cn)
+		}
+	}
+	_, err := s.config()
+	return err
+}
+
+// config returns the tls.Config described by s, without the certificate,
+// which the server loads from Cert and Key
+func (s tlsSettings) config() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.MinVersion != "" {
+		version, known := tlsVersions[s.MinVersion]
+		if !known {
+			return nil, fmt.Errorf("tls: unknown minimum version %q", s.MinVersion)
+		}
+		cfg.MinVersion = version
+	}
+
+	if len(s.CipherSuites) > 0 {
+		ids := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			ids[suite.Name] = suite.ID
+		}
+		for _, name := range s.CipherSuites {
+			id, known := ids[name]
+			if !known {
+				return nil, fmt.Errorf("tls: unknown or insecure cipher suite %q", name)
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+
+	if s.ClientCA != "" {
+		pem, err := os.ReadFile(s.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates in %s", s.ClientCA)
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if len(s.ClientCNs) > 0 {
+		cfg.VerifyConnection = s.verifyClientCN
+	}
+	return cfg, nil
+}
+
+// verifyClientCN rejects connections from clients whose verified
+// certificate's common name is not one of ClientCNs
+func (s tlsSettings) verifyClientCN(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: no client certificate")
+	}
+	cn := cs.PeerCertificates[0].Subject.CommonName
+	if !slices.Contains(s.ClientCNs, cn) {
+		return fmt.Errorf("tls: client %q is not authorized", cn)
+	}
+	return nil
+}
+
+// clientScopes returns the scope granted to each client common name by
+// ClientScopes, which must be valid
+func (s tlsSettings) clientScopes() map[string]scope {
+	granted := make(map[string]scope, len(s.ClientScopes))
+	for cn, name := range s.ClientScopes {
+		granted[cn] = scopes[name]
+	}
+	return granted
+}
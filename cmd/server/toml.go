@@ -0,0 +1,495 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlParser parses the subset of TOML 1.0 used by config files: tables,
+// arrays of tables, dotted and quoted keys, strings of all four kinds,
+// integers, floats, booleans, arrays and inline tables. Dates, times,
+// inf and nan, which no setting takes, are rejected. Redefining a key is an error, but a table header may be
+// repeated to add keys to the table.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// parseTOML parses the TOML document src into nested maps, holding
+// strings, int64s, float64s, bools, []anys and map[string]anys, as
+// decoding JSON into an any would
+func parseTOML(src string) (map[string]any, error) {
+	p := &tomlParser{src: src, line: 1}
+	root := map[string]any{}
+	if err := p.document(root); err != nil {
+		return nil, fmt.Errorf("toml: line %d: %w", p.line, err)
+	}
+	return root, nil
+}
+
+// document parses the key/value pairs and table headers of the document
+// into root
+func (p *tomlParser) document(root map[string]any) error {
+	current := root
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			current, err = p.header(root)
+		} else {
+			err = p.keyValue(current)
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.endOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// header parses a [table] or [[array of tables]] header and returns the
+// table that the following pairs go into
+func (p *tomlParser) header(root map[string]any) (map[string]any, error) {
+	p.pos++
+	array := p.consume("[")
+	p.skipBlank(false)
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank(false)
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !p.consume(closing) {
+		return nil, fmt.Errorf("expected %q after table name", closing)
+	}
+
+	parent, err := descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	if !array {
+		return descend(parent, []string{last})
+	}
+	table := map[string]any{}
+	switch existing := parent[last].(type) {
+	case nil:
+		parent[last] = []any{table}
+	case []any:
+		parent[last] = append(existing, table)
+	default:
+		return nil, fmt.Errorf("%q is not an array of tables", last)
+	}
+	return table, nil
+}
+
+// descend returns the table at the path keys under table, creating the
+// tables that are missing. A path through an array of tables goes into
+// its last table.
+func descend(table map[string]any, keys []string) (map[string]any, error) {
+	for _, key := range keys {
+		switch next := table[key].(type) {
+		case nil:
+			child := map[string]any{}
+			table[key] = child
+			table = child
+		case map[string]any:
+			table = next
+		case []any:
+			last, ok := next[len(next)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%q is not a table", key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%q is not a table", key)
+		}
+	}
+	return table, nil
+}
+
+// keyValue parses a key = value pair into table
+func (p *tomlParser) keyValue(table map[string]any) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipBlank(false)
+	if !p.consume("=") {
+		return fmt.Errorf("expected = after key %q", strings.Join(keys, "."))
+	}
+	p.skipBlank(false)
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	parent, err := descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, found := parent[last]; found {
+		return fmt.Errorf("key %q defined twice", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// key parses a key of bare or quoted parts separated by dots
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		var part string
+		switch {
+		case p.eof():
+			return nil, fmt.Errorf("expected a key")
+		case p.peek() == '"':
+			s, err := p.basicString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		case p.peek() == '\'':
+			s, err := p.literalString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, fmt.Errorf("unexpected %q where a key was expected", p.peek())
+			}
+			part = p.src[start:p.pos]
+		}
+		keys = append(keys, part)
+
+		p.skipBlank(false)
+		if !p.consume(".") {
+			return keys, nil
+		}
+		p.skipBlank(false)
+	}
+}
+
+// isBareKeyChar reports whether c may appear in a bare key
+func isBareKeyChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// value parses a value
+func (p *tomlParser) value() (any, error) {
+	if p.eof() {
+		return nil, fmt.Errorf("expected a value")
+	}
+	switch c := p.peek(); {
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		return p.multilineString(`"""`)
+	case strings.HasPrefix(p.src[p.pos:], "'''"):
+		return p.multilineString("'''")
+	case c == '"':
+		return p.basicString()
+	case c == '\'':
+		return p.literalString()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case p.consume("true"):
+		return true, nil
+	case p.consume("false"):
+		return false, nil
+	}
+	return p.number()
+}
+
+// basicString parses a "string" with escapes
+func (p *tomlParser) basicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", fmt.Errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// escape parses the escape sequence following a backslash into b
+func (p *tomlParser) escape(b *strings.Builder) error {
+	if p.eof() {
+		return fmt.Errorf("unterminated string")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if len(p.src)-p.pos < n {
+			return fmt.Errorf("short unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return fmt.Errorf("invalid unicode escape %q", p.src[p.pos:p.pos+n])
+		}
+		p.pos += n
+		b.WriteRune(rune(code))
+	default:
+		return fmt.Errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// literalString parses a 'string' without escapes
+func (p *tomlParser) literalString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// multilineString parses a string delimited by three quotes, double for
+// a basic string and single for a literal one. A newline right after the opening
+// delimiter is dropped, and in basic strings a backslash at the end of a
+// line drops the line break and the whitespace after it.
+func (p *tomlParser) multilineString(quotes string) (string, error) {
+	p.pos += len(quotes)
+	if p.consume("\r\n") || p.consume("\n") {
+		p.line++
+	}
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", fmt.Errorf("unterminated multi-line string")
+		}
+		if p.consume(quotes) {
+			// Up to two quotes may end the string just before the
+			// delimiter
+			for range 2 {
+				if p.eof() || p.peek() != quotes[0] {
+					break
+				}
+				b.WriteByte(quotes[0])
+				p.pos++
+			}
+			return b.String(), nil
+		}
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == '\n':
+			p.line++
+			b.WriteByte(c)
+		case c == '\\' && quotes == `"""`:
+			rest := strings.TrimLeft(p.src[p.pos:], " \t\r")
+			if strings.HasPrefix(rest, "\n") {
+				// A line-ending backslash
+				for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+					if p.peek() == '\n' {
+						p.line++
+					}
+					p.pos++
+				}
+				continue
+			}
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// array parses an array, which may span lines
+func (p *tomlParser) array() ([]any, error) {
+	p.pos++
+	values := []any{}
+	for {
+		p.skipBlank(true)
+		if p.consume("]") {
+			return values, nil
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		p.skipBlank(true)
+		if p.consume("]") {
+			return values, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+// inlineTable parses an inline table, which must fit on one line
+func (p *tomlParser) inlineTable() (map[string]any, error) {
+	p.pos++
+	table := map[string]any{}
+	p.skipBlank(false)
+	if p.consume("}") {
+		return table, nil
+	}
+	for {
+		p.skipBlank(false)
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if p.consume("}") {
+			return table, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected , or } in inline table")
+		}
+	}
+}
+
+// number parses an integer or a float
+func (p *tomlParser) number() (any, error) {
+	start := p.pos
+	for !p.eof() && strings.IndexByte("0123456789abcdefABCDEFxo_+-.:", p.peek()) >= 0 {
+		p.pos++
+	}
+	token := p.src[start:p.pos]
+	if token == "" {
+		return nil, fmt.Errorf("unexpected %q where a value was expected", p.peek())
+	}
+	if strings.Contains(token, ":") || strings.Count(token, "-") > 1 && !strings.ContainsAny(token, "eE") {
+		return nil, fmt.Errorf("dates and times are not supported: %q", token)
+	}
+	if strings.HasPrefix(token, "_") || strings.HasSuffix(token, "_") || strings.Contains(token, "__") {
+		return nil, fmt.Errorf("invalid number %q", token)
+	}
+	digits := strings.ReplaceAll(token, "_", "")
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if rest, found := strings.CutPrefix(digits, prefix); found {
+			n, err := strconv.ParseInt(rest, base, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer %q", token)
+			}
+			return n, nil
+		}
+	}
+	if !strings.ContainsAny(digits, ".eE") {
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", token)
+		}
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid float %q", token)
+	}
+	return f, nil
+}
+
+// skipBlank skips spaces and tabs, and also comments and line breaks if
+// lines is set
+func (p *tomlParser) skipBlank(lines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case lines && c == '#':
+			p.skipComment()
+		case lines && c == '\n':
+			p.line++
+			p.pos++
+		case lines && c == '\r' && strings.HasPrefix(p.src[p.pos:], "\r\n"):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// skipComment skips a comment up to the end of the line
+func (p *tomlParser) skipComment() {
+	if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+		p.pos += end
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+// endOfLine checks that nothing but a comment follows on the line
+func (p *tomlParser) endOfLine() error {
+	p.skipBlank(false)
+	if !p.eof() && p.peek() == '#' {
+		p.skipComment()
+	}
+	p.consume("\r")
+	if !p.eof() && p.peek() != '\n' {
+		return fmt.Errorf("unexpected %q at the end of the line", p.peek())
+	}
+	return nil
+}
+
+// eof reports whether the whole input has been read
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// peek returns the next byte, which must exist
+func (p *tomlParser) peek() byte {
+	return p.src[p.pos]
+}
+
+// consume skips s if the input continues with it and reports whether it
+// did
+func (p *tomlParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
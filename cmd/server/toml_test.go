@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	src := `
+# a comment
+title = "basic \"quoted\" \u00e9\ttab" # trailing comment
+path = 'C:\literal'
+bare-key_1 = 1_000
+"quoted key" = -16
+dotted.inner = true
+hex = 0xff
+oct = 0o17
+bin = 0b101
+float = 6.5e-1
+neg = -3.25
+list = [ 1, 2,
+  3, # comments and line breaks are allowed in arrays
+]
+nested = [[1], ["a"]]
+inline = { a = 1, b.c = "x" }
+multi = """
+one \
+   two"""
+raw = '''
+no \escapes'''
+
+[server]
+addr = ":8080"
+
+[server.tls]
+cert = "c.pem"
+
+[[caches]]
+name = "a"
+
+[[caches]]
+name = "b"
+[caches.extra]
+x = 1
+`
+	got, err := parseTOML(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"title":      "basic \"quoted\" é\ttab",
+		"path":       `C:\literal`,
+		"bare-key_1": int64(1000),
+		"quoted key": int64(-16),
+		"dotted":     map[string]any{"inner": true},
+		"hex":        int64(255),
+		"oct":        int64(15),
+		"bin":        int64(5),
+		"float":      0.65,
+		"neg":        -3.25,
+		"list":       []any{int64(1), int64(2), int64(3)},
+		"nested":     []any{[]any{int64(1)}, []any{"a"}},
+		"inline":     map[string]any{"a": int64(1), "b": map[string]any{"c": "x"}},
+		"multi":      "one two",
+		"raw":        `no \escapes`,
+		"server": map[string]any{
+			"addr": ":8080",
+			"tls":  map[string]any{"cert": "c.pem"},
+		},
+		"caches": []any{
+			map[string]any{"name": "a"},
+			map[string]any{"name": "b", "extra": map[string]any{"x": int64(1)}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"a = 1\na = 2", "line 2"},
+		{"a.b = 1\na = 2", "line 2"},
+		{"[t]\nx = 1\n[t.x]", "line 3"},
+		{"d = 1979-05-27", "dates"},
+		{"t = 07:32:00", "dates"},
+		{"f = inf", "unexpected"},
+		{"s = \"unterminated", "line 1"},
+		{"s = \"bad \\q escape\"", "line 1"},
+		{"a = 1 b = 2", "line 1"},
+		{"a =", "line 1"},
+		{"n = 1__0", "invalid number"},
+		{"n = -0x10", "invalid integer"},
+		{"n = 99999999999999999999", "invalid integer"},
+		{"[t", "line 1"},
+		{"x = [1, 2", "line 1"},
+		{"x = { a = 1,\n b = 2 }", "line 1"},
+	}
+	for _, tt := range tests {
+		_, err := parseTOML(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseTOML(%q) error = %v, want one mentioning %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+func TestLoadTOMLMatchesJSON(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{
+			"addr": ":9000",
+			"capacity": 10,
+			"shutdown_timeout": "5s",
+			"max_body_bytes": 2048,
+			"tls": {"cert": "c.pem", "cipher_suites": ["TLS_AES_128_GCM_SHA256"]},
+			"jwt": {"secret": "s", "scopes": {"cache:read": "read"}},
+			"rate_limit": {"rps": 2.5},
+			"caches": [{"name": "a", "capacity": 5, "ttl": 60}, {"name": "b", "capacity": 7, "ttl": 0, "sliding": true}]
+		}`,
+		"config.toml": `
+addr = ":9000"
+capacity = 10
+shutdown_timeout = "5s"
+max_body_bytes = 2048
+rate_limit.rps = 2.5
+
+[tls]
+cert = "c.pem"
+cipher_suites = ["TLS_AES_128_GCM_SHA256"]
+
+[jwt]
+secret = "s"
+scopes = { "cache:read" = "read" }
+
+[[caches]]
+name = "a"
+capacity = 5
+ttl = 60
+
+[[caches]]
+name = "b"
+capacity = 7
+ttl = 0
+sliding = true
+`,
+	}
+	loaded := make(map[string]config)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg := defaultConfig()
+		if err := cfg.load(path); err != nil {
+			t.Fatalf("load(%s): %v", name, err)
+		}
+		loaded[name] = cfg
+	}
+	if !reflect.DeepEqual(loaded["config.toml"], loaded["config.json"]) {
+		t.Errorf("TOML config =\n%+v\nJSON config =\n%+v", loaded["config.toml"], loaded["config.json"])
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tracingSettings configures tracing. Spans are exported with OTLP over
+// HTTP, in its JSON encoding, to the collector at Endpoint, such as
+// "http://localhost:4318"; tracing is disabled if it is empty.
+// ServiceName identifies the server in traces and defaults to "lru".
+type tracingSettings struct {
+	Endpoint    string `json:"endpoint"`
+	ServiceName string `json:"service_name"`
+}
+
+// validate checks that Endpoint, if set, is an HTTP URL
+func (s tracingSettings) validate() error {
+	if s.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("tracing: endpoint %q is not an HTTP URL", s.Endpoint)
+	}
+	return nil
+}
+
+const (
+	// spanBatch is the number of spans exported in one request at most
+	spanBatch = 512
+	// spanQueue is the number of ended spans waiting for export beyond
+	// which new ones are dropped
+	spanQueue = 4096
+	// exportInterval is the longest a span waits for export
+	exportInterval = 5 * time.Second
+)
+
+// spanKind is the OTLP kind of a span
+type spanKind int
+
+const (
+	spanInternal spanKind = 1
+	spanServer   spanKind = 2
+	spanClient   spanKind = 3
+)
+
+// spanContext identifies a span across processes, as a W3C traceparent
+// header carries it
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+// parseTraceparent parses a version 00 traceparent header, or a later
+// version's leading fields
+func parseTraceparent(s string) (spanContext, bool) {
+	var sc spanContext
+	fields := strings.Split(s, "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" ||
+		fields[0] == "00" && len(fields) != 4 {
+		return sc, false
+	}
+	// Check the lengths first, as hex.Decode writes past the end of the
+	// IDs given longer fields
+	if len(fields[1]) != 2*len(sc.traceID) || len(fields[2]) != 2*len(sc.spanID) || len(fields[3]) != 2 {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(fields[3])
+	if err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.traceID[:], []byte(fields[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(fields[2])); err != nil {
+		return sc, false
+	}
+	if sc.traceID == [16]byte{} || sc.spanID == [8]byte{} {
+		return sc, false
+	}
+	sc.sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// traceparent returns the traceparent header carrying sc
+func (sc spanContext) traceparent() string {
+	flags := "00"
+	if sc.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.traceID[:]) + "-" + hex.EncodeToString(sc.spanID[:]) + "-" + flags
+}
+
+// span is an operation being traced. A nil *span is a valid span that
+// records nothing, so that callers need not check whether tracing is on.
+type span struct {
+	spanContext
+	tracer *Tracer
+	parent [8]byte // zero for a root span
+	name   string
+	kind   spanKind
+	start  time.Time
+	end    time.Time
+	attrs  map[string]any // string or int values
+	failed bool
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// spanFrom returns the current span of ctx, or nil if there is none
+func spanFrom(ctx context.Context) *span {
+	sp, _ := ctx.Value(spanKey{}).(*span)
+	return sp
+}
+
+// startSpan starts a span named name as a child of the current span of
+// ctx and returns ctx with it as the current span. Without a current
+// span it starts nothing and returns ctx and nil.
+func startSpan(ctx context.Context, name string, kind spanKind) (context.Context, *span) {
+	parent := spanFrom(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	sp := &span{
+		spanContext: spanContext{traceID: parent.traceID, sampled: parent.sampled},
+		tracer:      parent.tracer,
+		parent:      parent.spanID,
+		name:        name,
+		kind:        kind,
+		start:       time.Now(),
+		attrs:       map[string]any{},
+	}
+	rand.Read(sp.spanID[:])
+	return context.WithValue(ctx, spanKey{}, sp), sp
+}
+
+// set sets the attribute key of sp to value, a string or an int
+func (sp *span) set(key string, value any) {
+	if sp != nil {
+		sp.attrs[key] = value
+	}
+}
+
+// fail marks sp as failed with err
+func (sp *span) fail(err error) {
+	if sp != nil {
+		sp.failed = true
+		sp.attrs["error.message"] = err.Error()
+	}
+}
+
+// finish ends sp and queues it for export if it is sampled
+func (sp *span) finish() {
+	if sp == nil || !sp.sampled {
+		return
+	}
+	sp.end = time.Now()
+	t := sp.tracer
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return
+	}
+	select {
+	case t.spans <- sp:
+	default:
+		// The collector is not keeping up
+	}
+}
+
+// Tracer starts a server span for every request and exports the spans
+// of sampled traces in batches
+type Tracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+	spans    chan *span
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewTracer returns a Tracer configured by s that exports spans until it
+// is closed, or nil if tracing is disabled
+func NewTracer(s tracingSettings) *Tracer {
+	if s.Endpoint == "" {
+		return nil
+	}
+	t := &Tracer{
+		endpoint: strings.TrimSuffix(s.Endpoint, "/") + "/v1/traces",
+		service:  s.ServiceName,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan *span, spanQueue),
+		done:     make(chan struct{}),
+	}
+	if t.service == "" {
+		t.service = "lru"
+	}
+	go t.run()
+	return t
+}
+
+// run exports the queued spans every exportInterval, or as soon as a
+// batch is full, until the queue is closed
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	var batch []*span
+	for {
+		select {
+		case sp, ok := <-t.spans:
+			if !ok {
+				t.export(batch)
+				return
+			}
+			if batch = append(batch, sp); len(batch) == spanBatch {
+				t.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			t.export(batch)
+			batch = nil
+		}
+	}
+}
+
+// export sends spans to the collector. Failures are logged and the spans
+// dropped.
+func (t *Tracer) export(spans []*span) {
+	if len(spans) == 0 {
+		return
+	}
+	body, _ := json.Marshal(t.request(spans))
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("exporting spans failed", "err", err, "spans", len(spans))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("exporting spans failed", "status", resp.Status, "spans", len(spans))
+	}
+}
+
+// otlpAttributes returns attrs as OTLP key-values
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	kvs := make([]map[string]any, 0, len(attrs))
+	for key, value := range attrs {
+		var v map[string]any
+		switch value := value.(type) {
+		case int:
+			// 64-bit integers are strings in OTLP's JSON encoding
+			v = map[string]any{"intValue": strconv.Itoa(value)}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(value)}
+		}
+		kvs = append(kvs, map[string]any{"key": key, "value": v})
+	}
+	return kvs
+}
+
+// request returns the OTLP export request for spans
+func (t *Tracer) request(spans []*span) map[string]any {
+	otlpSpans := make([]map[string]any, len(spans))
+	for i, sp := range spans {
+		s := map[string]any{
+			"traceId":           hex.EncodeToString(sp.traceID[:]),
+			"spanId":            hex.EncodeToString(sp.spanID[:]),
+			"name":              sp.name,
+			"kind":              sp.kind,
+			"startTimeUnixNano": strconv.FormatInt(sp.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(sp.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(sp.attrs),
+		}
+		if sp.parent != [8]byte{} {
+			s["parentSpanId"] = hex.EncodeToString(sp.parent[:])
+		}
+		if sp.failed {
+			s["status"] = map[string]any{"code": 2} // error
+		}
+		otlpSpans[i] = s
+	}
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource": map[string]any{"attributes": otlpAttributes(map[string]any{"service.name": t.service})},
+		"scopeSpans": []any{map[string]any{
+			"scope": map[string]any{"name": "github.com/NithinkumarHV/LRU/cmd/server"},
+			"spans": otlpSpans,
+		}},
+	}}}
+}
+
+// Close exports the spans that are queued and stops t. Spans ended
+// afterwards are dropped.
+func (t *Tracer) Close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.spans)
+	}
+	t.mu.Unlock()
+	<-t.done
+}
+
+// Trace wraps handler to serve each request in a server span, continuing
+// the trace of its traceparent header if it has a valid one and starting
+// a sampled trace otherwise. Spans are named by the pattern the request
+// matched. Without a Tracer, handler is returned as it is.
+func (t *Tracer) Trace(handler http.Handler) http.Handler {
+	if t == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sp := &span{tracer: t, kind: spanServer, start: time.Now(), attrs: map[string]any{}}
+		if parent, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			sp.traceID, sp.parent, sp.sampled = parent.traceID, parent.spanID, parent.sampled
+		} else {
+			rand.Read(sp.traceID[:])
+			sp.sampled = true
+		}
+		rand.Read(sp.spanID[:])
+		r = r.WithContext(context.WithValue(r.Context(), spanKey{}, sp))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// The mux has matched r by now, so its pattern is set
+		sp.name = r.Method
+		if route := r.Pattern; route != "" {
+			if _, path, found := strings.Cut(route, " "); found {
+				route = path
+			}
+			sp.name += " " + route
+			sp.set("http.route", route)
+		}
+		sp.set("http.request.method", r.Method)
+		sp.set("url.path", r.URL.Path)
+		sp.set("http.response.status_code", rec.status)
+		if client, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			sp.set("client.address", client)
+		}
+		sp.failed = rec.status >= 500
+		sp.finish()
+	})
+}
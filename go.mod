@@ -0,0 +1,3 @@
+module github.com/NithinkumarHV/LRU
+
+go 1.24
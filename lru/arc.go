@@ -0,0 +1,158 @@
+package lru
+
+// Lists of an arcPolicy. t1 and t2 hold resident keys seen once and more
+// than once recently; b1 and b2 are their ghost lists, holding keys that
+// were recently evicted from them.
+const (
+	arcT1 = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+// arcPolicy implements the Adaptive Replacement Cache algorithm of
+// Megiddo and Modha. It balances a recency list and a frequency list,
+// adapting the target size p of the recency list whenever an evicted key
+// is requested again.
+type arcPolicy[K comparable] struct {
+	capacity int
+	p        int // target size of t1
+	lists    [4]*linkedList[K]
+	entries  map[K]*arcEntry[K]
+	victim   *arcEntry[K] // last key returned by Victim
+	b2Hit    bool         // whether the last insertion was a b2 ghost hit
+}
+
+// arcEntry records which list a key is on.
+type arcEntry[K comparable] struct {
+	elem  *node[K]
+	where int
+}
+
+// NewARCPolicy returns an Adaptive Replacement Cache eviction policy,
+// which self-tunes between recency and frequency and so copes with
+// workloads that alternate between scans and hot sets.
+func NewARCPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	p := &arcPolicy[K]{
+		capacity: capacity,
+		entries:  make(map[K]*arcEntry[K]),
+	}
+	for i := range p.lists {
+		p.lists[i] = newLinkedList[K]()
+	}
+	return p
+}
+
+func (p *arcPolicy[K]) OnGet(key K) {
+	if e, found := p.entries[key]; found && p.resident(e) {
+		p.move(key, e, arcT2)
+	}
+}
+
+func (p *arcPolicy[K]) OnSet(key K) {
+	e, found := p.entries[key]
+	p.b2Hit = false
+	switch {
+	case !found:
+		p.entries[key] = &arcEntry[K]{elem: p.lists[arcT1].PushFront(key), where: arcT1}
+	case p.resident(e):
+		p.move(key, e, arcT2)
+	case e.where == arcB1:
+		// A recently evicted once-seen key came back: favor recency.
+		p.p = min(p.capacity, p.p+max(p.lists[arcB2].Len()/p.lists[arcB1].Len(), 1))
+		p.move(key, e, arcT2)
+	case e.where == arcB2:
+		// A recently evicted frequent key came back: favor frequency.
+		p.p = max(0, p.p-max(p.lists[arcB1].Len()/p.lists[arcB2].Len(), 1))
+		p.b2Hit = true
+		p.move(key, e, arcT2)
+	}
+}
+
+func (p *arcPolicy[K]) OnRemove(key K) {
+	e, found := p.entries[key]
+	if !found || !p.resident(e) {
+		return
+	}
+	if e != p.victim {
+		// Deleted or expired rather than evicted; keep no ghost.
+		p.lists[e.where].Remove(e.elem)
+		delete(p.entries, key)
+		return
+	}
+
+	p.victim = nil
+	if e.where == arcT1 {
+		p.move(key, e, arcB1)
+	} else {
+		p.move(key, e, arcB2)
+	}
+	p.trimGhosts()
+}
+
+func (p *arcPolicy[K]) Victim() (K, bool) {
+	t1, t2 := p.lists[arcT1], p.lists[arcT2]
+	var elem *node[K]
+	switch {
+	case t1.Len() > 0 && (t1.Len() > p.p || p.b2Hit && t1.Len() == p.p) || t2.Len() == 0:
+		elem = t1.Back()
+	default:
+		elem = t2.Back()
+	}
+	if elem == nil {
+		var zero K
+		return zero, false
+	}
+	key := elem.Value
+	p.victim = p.entries[key]
+	return key, true
+}
+
+func (p *arcPolicy[K]) walk(f func(key K)) {
+	for _, l := range []*linkedList[K]{p.lists[arcT2], p.lists[arcT1]} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			f(elem.Value)
+		}
+	}
+}
+
+func (p *arcPolicy[K]) resize(capacity int) {
+	p.capacity = capacity
+	p.p = min(p.p, capacity)
+	p.trimGhosts()
+}
+
+// resident reports whether e is on t1 or t2.
+func (p *arcPolicy[K]) resident(e *arcEntry[K]) bool {
+	return e.where == arcT1 || e.where == arcT2
+}
+
+// move puts key at the front of the given list.
+func (p *arcPolicy[K]) move(key K, e *arcEntry[K], where int) {
+	p.lists[e.where].Remove(e.elem)
+	e.elem = p.lists[where].PushFront(key)
+	e.where = where
+}
+
+// trimGhosts drops the oldest ghosts so that t1 and b1 together hold at
+// most capacity keys and all four lists at most twice that.
+func (p *arcPolicy[K]) trimGhosts() {
+	t1, t2, b1, b2 := p.lists[arcT1], p.lists[arcT2], p.lists[arcB1], p.lists[arcB2]
+	for b1.Len() > 0 && t1.Len()+b1.Len() > p.capacity {
+		p.dropGhost(b1)
+	}
+	for b1.Len()+b2.Len() > 0 && t1.Len()+t2.Len()+b1.Len()+b2.Len() > 2*p.capacity {
+		if b2.Len() > 0 {
+			p.dropGhost(b2)
+		} else {
+			p.dropGhost(b1)
+		}
+	}
+}
+
+// dropGhost forgets the oldest key of a ghost list.
+func (p *arcPolicy[K]) dropGhost(l *linkedList[K]) {
+	elem := l.Back()
+	delete(p.entries, elem.Value)
+	l.Remove(elem)
+}
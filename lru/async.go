@@ -0,0 +1,110 @@
+package lru
+
+import (
+	"fmt"
+	"time"
+)
+
+// write is a Set or SetWithTTL queued by a cache created with
+// WithAsyncWrites, or a Flush marker if flushed is not nil.
+type write[K comparable, V any] struct {
+	key        K
+	value      V
+	ttl        time.Duration
+	defaultTTL bool          // whether to apply the default TTL instead of ttl
+	flushed    chan struct{} // closed once the writes queued before it are applied
+}
+
+// WithAsyncWrites makes Set and SetWithTTL queue their writes on a channel
+// holding up to buffer writes and return straight away; a background
+// goroutine applies them in order, taking the lock once for as many as
+// are queued. This smooths the latency of bursty writers, at the cost of
+// reads briefly missing a write that is still queued. Writers block while
+// the queue is full. Other methods that write, such as Delete or
+// SetIfAbsent, first apply the writes already queued, so they never take
+// effect before an earlier Set. It panics if buffer < 1.
+func WithAsyncWrites(buffer int) Option {
+	if buffer < 1 {
+		panic(fmt.Sprintf("lru: async write buffer %d less than 1", buffer))
+	}
+	return func(o *options) {
+		o.writeBuffer = buffer
+	}
+}
+
+// Flush waits until the writes queued by Set and SetWithTTL have been
+// applied. It returns at once for a cache created without WithAsyncWrites
+// or once the cache is closed.
+func (lru *LRUCache[K, V]) Flush() {
+	if lru.writes == nil {
+		return
+	}
+	flushed := make(chan struct{})
+	if !lru.enqueue(write[K, V]{flushed: flushed}) {
+		return
+	}
+	select {
+	case <-flushed:
+	case <-lru.done:
+	}
+}
+
+// enqueue queues w for applyWrites, blocking while the queue is full. It
+// reports false, dropping w, if the cache is closed.
+func (lru *LRUCache[K, V]) enqueue(w write[K, V]) bool {
+	select {
+	case lru.writes <- w:
+	case <-lru.done:
+		return false
+	}
+	select {
+	case lru.queued <- struct{}{}:
+	default:
+		// applyWrites has yet to take the previous signal, and will
+		// find w when it does.
+	}
+	return true
+}
+
+// applyWrites applies queued writes until the cache is closed. Writes
+// are only taken off the queue with lru.mu held, so that whoever holds it
+// sees every write queued so far.
+func (lru *LRUCache[K, V]) applyWrites() {
+	for {
+		select {
+		case <-lru.queued:
+			lru.lock()
+			lru.applyQueued()
+			lru.unlock()
+		case <-lru.done:
+			return
+		}
+	}
+}
+
+// lockOrdered acquires lru.mu like lock and then applies the queued
+// writes, so that a write made by the caller comes after them.
+func (lru *LRUCache[K, V]) lockOrdered() {
+	lru.lock()
+	lru.applyQueued()
+}
+
+// applyQueued applies the writes queued so far, if any. The caller must
+// hold lru.mu.
+func (lru *LRUCache[K, V]) applyQueued() {
+	for n := len(lru.writes); n > 0; n-- {
+		lru.apply(<-lru.writes)
+	}
+}
+
+// apply performs a queued write. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) apply(w write[K, V]) {
+	switch {
+	case w.flushed != nil:
+		close(w.flushed)
+	case w.defaultTTL:
+		lru.set(w.key, w.value)
+	default:
+		lru.setTTL(w.key, w.value, w.ttl)
+	}
+}
@@ -0,0 +1,76 @@
+package lru
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncWritesApplyInOrder(t *testing.T) {
+	c := NewLRUCache[int, int](100, 0, WithAsyncWrites(4))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				if i%2 == 0 {
+					c.Set(g, i)
+				} else {
+					c.SetWithTTL(g, i, time.Hour)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	c.Flush()
+	for g := range 8 {
+		if v, _ := c.Get(g); v != 199 {
+			t.Errorf("Get(%d) = %d after Flush, want the last write, 199", g, v)
+		}
+	}
+}
+
+func TestFlushAfterClose(t *testing.T) {
+	c := NewLRUCache[int, int](10, 0, WithAsyncWrites(1))
+	c.Set(1, 1)
+	c.Close()
+	done := make(chan struct{})
+	go func() {
+		c.Flush()
+		c.Set(2, 2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush or Set blocked on a closed cache")
+	}
+}
+
+func TestSynchronousWritesFollowQueuedOnes(t *testing.T) {
+	c := NewLRUCache[int, int](100, 0, WithAsyncWrites(64))
+	defer c.Close()
+
+	for i := range 1000 {
+		c.Set(i, 1)
+		if !c.SetIfPresent(i, 2, NoExpiration) {
+			t.Fatalf("SetIfPresent(%d) did not see the queued Set", i)
+		}
+		c.Set(i, 3)
+		if c.SetIfAbsent(i, 4, NoExpiration) {
+			t.Fatalf("SetIfAbsent(%d) did not see the queued Set", i)
+		}
+		if c.Delete(i); c.Contains(i) {
+			t.Fatalf("queued Set(%d) applied after Delete", i)
+		}
+	}
+	c.Set(-1, 1)
+	c.SetWithDeadline(-1, 2, time.Time{})
+	c.Flush()
+	if v, _ := c.Get(-1); v != 2 {
+		t.Errorf("Get(-1) = %d, want the value of the later SetWithDeadline", v)
+	}
+}
@@ -0,0 +1,65 @@
+package lru
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// bloomHashes is the number of bits a bloomFilter sets per key.
+const bloomHashes = 4
+
+// bloomBitsPerKey is the number of filter bits per remembered key, which
+// keeps the false positive rate near 1%.
+const bloomBitsPerKey = 10
+
+// bloomFilter remembers which keys it has seen, with false positives but
+// no false negatives. It forgets everything once it has seen as many keys
+// as it was sized for, so the false positive rate stays bounded.
+type bloomFilter[K comparable] struct {
+	seed  maphash.Seed
+	bits  []uint64
+	mask  uint64
+	added int
+	limit int
+}
+
+// newBloomFilter returns a filter sized to remember n keys.
+func newBloomFilter[K comparable](n int) *bloomFilter[K] {
+	n = max(n, 1)
+	size := 1 << bits.Len(uint(n*bloomBitsPerKey-1))
+	return &bloomFilter[K]{
+		seed:  maphash.MakeSeed(),
+		bits:  make([]uint64, (size+63)/64),
+		mask:  uint64(size - 1),
+		limit: n,
+	}
+}
+
+// testAndAdd adds key to the filter and reports whether it was already
+// there.
+func (f *bloomFilter[K]) testAndAdd(key K) bool {
+	h := maphash.Comparable(f.seed, key)
+	h1, h2 := h, h>>32|1
+	seen := true
+	for i := range uint64(bloomHashes) {
+		bit := (h1 + i*h2) & f.mask
+		word, m := &f.bits[bit/64], uint64(1)<<(bit%64)
+		if *word&m == 0 {
+			seen = false
+			*word |= m
+		}
+	}
+	if !seen {
+		f.added++
+		if f.added >= f.limit {
+			f.reset()
+		}
+	}
+	return seen
+}
+
+// reset empties the filter.
+func (f *bloomFilter[K]) reset() {
+	clear(f.bits)
+	f.added = 0
+}
@@ -0,0 +1,41 @@
+package lru
+
+// GetVersion retrieves the value of the key together with its version.
+// Every write to a key assigns it a new version, which can be passed to
+// CompareAndSwap to update the key only if nobody else has written it in
+// the meantime. A missing key reports version 0.
+func (lru *LRUCache[K, V]) GetVersion(key K) (V, uint64, bool) {
+	lru.lock()
+	defer lru.unlock()
+
+	v, found := lru.get(key)
+	if !found {
+		return v, 0, false
+	}
+	return v, lru.cache[key].version, true
+}
+
+// CompareAndSwap stores value under key only if the key's current version
+// equals version. A version of 0 means the key must be absent. On success
+// it returns the new version and true; on conflict it returns the current
+// version and false. It also returns false, with version 0, if the cache
+// does not keep value: the cache may be closed, the admit hook or the
+// doorkeeper may veto it, it may outweigh the whole capacity, or the
+// eviction policy may evict it straight away. The key is then missing, as the old value is dropped.
+func (lru *LRUCache[K, V]) CompareAndSwap(key K, version uint64, value V) (uint64, bool) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	var current uint64
+	if _, found := lru.get(key); found {
+		current = lru.cache[key].version
+	}
+	if current != version {
+		return current, false
+	}
+	if lru.set(key, value) == nil {
+		// A rejected write leaves the key missing
+		return 0, false
+	}
+	return lru.version, true
+}
@@ -0,0 +1,107 @@
+package lru
+
+// clockPolicy implements the CLOCK, or second-chance, approximation of
+// LRU. Keys sit in a ring with a referenced bit that reads merely set, so
+// a Get costs no list manipulation. To find a victim the hand sweeps the
+// ring, clearing set bits, and stops at the first key whose bit is clear.
+type clockPolicy[K comparable] struct {
+	ring  *linkedList[*clockEntry[K]] // of *clockEntry, treated as circular
+	elems map[K]*node[*clockEntry[K]]
+	hand  *node[*clockEntry[K]] // next entry to examine
+}
+
+// clockEntry is a key in the ring of a clockPolicy.
+type clockEntry[K comparable] struct {
+	key        K
+	referenced bool
+}
+
+// NewClockPolicy returns a CLOCK eviction policy, which trades a little
+// hit rate for much cheaper reads than NewLRUPolicy.
+func NewClockPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &clockPolicy[K]{
+		ring:  newLinkedList[*clockEntry[K]](),
+		elems: make(map[K]*node[*clockEntry[K]]),
+	}
+}
+
+func (p *clockPolicy[K]) OnGet(key K) {
+	if elem, found := p.elems[key]; found {
+		elem.Value.referenced = true
+	}
+}
+
+func (p *clockPolicy[K]) OnSet(key K) {
+	if elem, found := p.elems[key]; found {
+		elem.Value.referenced = true
+		return
+	}
+	// Insert just behind the hand so the new key is examined last.
+	e := &clockEntry[K]{key: key}
+	if p.hand == nil {
+		p.hand = p.ring.PushBack(e)
+		p.elems[key] = p.hand
+		return
+	}
+	p.elems[key] = p.ring.InsertBefore(e, p.hand)
+}
+
+func (p *clockPolicy[K]) OnRemove(key K) {
+	elem, found := p.elems[key]
+	if !found {
+		return
+	}
+	if elem == p.hand {
+		p.hand = p.next(elem)
+		if p.hand == elem {
+			p.hand = nil
+		}
+	}
+	p.ring.Remove(elem)
+	delete(p.elems, key)
+}
+
+func (p *clockPolicy[K]) Victim() (K, bool) {
+	if p.hand == nil {
+		var zero K
+		return zero, false
+	}
+	for {
+		e := p.hand.Value
+		if !e.referenced {
+			return e.key, true
+		}
+		e.referenced = false
+		p.hand = p.next(p.hand)
+	}
+}
+
+func (p *clockPolicy[K]) walk(f func(key K)) {
+	if p.hand == nil {
+		return
+	}
+	// Walk backwards from just behind the hand, from the keys the hand
+	// will reach last to the one it examines next.
+	for elem := p.prev(p.hand); ; elem = p.prev(elem) {
+		f(elem.Value.key)
+		if elem == p.hand {
+			return
+		}
+	}
+}
+
+// next returns the element after elem in the ring.
+func (p *clockPolicy[K]) next(elem *node[*clockEntry[K]]) *node[*clockEntry[K]] {
+	if n := elem.Next(); n != nil {
+		return n
+	}
+	return p.ring.Front()
+}
+
+// prev returns the element before elem in the ring.
+func (p *clockPolicy[K]) prev(elem *node[*clockEntry[K]]) *node[*clockEntry[K]] {
+	if n := elem.Prev(); n != nil {
+		return n
+	}
+	return p.ring.Back()
+}
@@ -0,0 +1,42 @@
+package lru
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrClosed is returned by Close, GetOrLoad and GetOrLoadStale once the
+// cache is closed.
+var ErrClosed = errors.New("lru: cache is closed")
+
+// Close stops the cache's background goroutines and removes all entries,
+// calling OnEvict for them as Clear does. Afterwards the cache stays
+// empty. Only the methods with an error result report ErrClosed: GetOrLoad
+// and GetOrLoadStale return it, even to callers whose load was under way
+// when the cache was closed, and closing an already closed cache returns
+// it. The others cannot report it: writes are dropped, with SetIfAbsent,
+// SetIfPresent, CompareAndSwap and Incr reporting failure, reads miss and
+// Flush returns at once.
+func (lru *LRUCache[K, V]) Close() error {
+	lru.lock()
+	defer lru.unlock()
+
+	if lru.closed {
+		return ErrClosed
+	}
+	lru.closed = true
+	close(lru.done)
+	lru.removeAll()
+	return nil
+}
+
+// sleep waits for d and reports whether the cache is still open, returning
+// early if it is closed in the meantime.
+func (lru *LRUCache[K, V]) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-lru.done:
+		return false
+	}
+}
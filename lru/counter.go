@@ -0,0 +1,37 @@
+package lru
+
+// Number is the set of value types that Incr and Decr can operate on.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// Incr atomically adds delta to the value stored under key and returns the
+// new value. A missing or expired key is treated as zero and inserted with
+// the cache's default expiration. Incrementing an existing key leaves its
+// expiration unchanged, so counters keep their original window. It reports
+// false if the cache does not keep the new value, because it is closed,
+// the admit hook or the doorkeeper vetoed it, it outweighs the whole
+// capacity or the eviction policy evicted it straight away; the key is
+// then missing.
+func Incr[K comparable, V Number](lru *LRUCache[K, V], key K, delta V) (V, bool) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	if _, found := lru.get(key); found {
+		// Storing the sum with the item's own deadline gives it a new
+		// version, reweighs it and notifies the replacement, as any
+		// other write does
+		item := lru.cache[key]
+		value := item.value + delta
+		return value, lru.store(key, value, item.deadline, item.ttl, keepPriority) != nil
+	}
+	return delta, lru.set(key, delta) != nil
+}
+
+// Decr atomically subtracts delta from the value stored under key and
+// returns the new value. It follows the same rules as Incr.
+func Decr[K comparable, V Number](lru *LRUCache[K, V], key K, delta V) (V, bool) {
+	return Incr(lru, key, -delta)
+}
@@ -0,0 +1,93 @@
+package lru
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expireBatch is the most expired items a cleanup cycle removes per lock
+// acquisition.
+const expireBatch = 20
+
+// expireBudget bounds the time a cleanup cycle spends removing expired
+// items. Items still due afterwards are left for the next cycle or for
+// lazy expiration.
+const expireBudget = 25 * time.Millisecond
+
+// expiryHeap is a min-heap of the items that can expire, ordered by
+// expireAt, so that cleanup only visits items that are due.
+type expiryHeap[K comparable, V any] []*CacheItem[K, V]
+
+func (h expiryHeap[K, V]) Len() int { return len(h) }
+
+func (h expiryHeap[K, V]) Less(i, j int) bool {
+	return h[i].expireAt.Before(h[j].expireAt)
+}
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].expiryIndex = i
+	h[j].expiryIndex = j
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	item := x.(*CacheItem[K, V])
+	item.expiryIndex = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	item.expiryIndex = -1
+	return item
+}
+
+// schedule updates the position of item in the expiry heap after its
+// expireAt changed. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) schedule(item *CacheItem[K, V]) {
+	switch {
+	case item.expireAt.IsZero():
+		lru.unschedule(item)
+	case item.expiryIndex >= 0:
+		heap.Fix(&lru.expiry, item.expiryIndex)
+	default:
+		heap.Push(&lru.expiry, item)
+		lru.startCleanup()
+	}
+}
+
+// unschedule removes item from the expiry heap if it is there. The caller
+// must hold lru.mu.
+func (lru *LRUCache[K, V]) unschedule(item *CacheItem[K, V]) {
+	if item.expiryIndex >= 0 {
+		heap.Remove(&lru.expiry, item.expiryIndex)
+	}
+}
+
+// expireCycle removes due items incrementally, in the manner of Redis'
+// active expiration: it takes the lock for one batch at a time and keeps
+// going only while batches come back full and the time budget lasts, so
+// readers and writers are never blocked for a whole sweep.
+func (lru *LRUCache[K, V]) expireCycle() {
+	deadline := time.Now().Add(expireBudget)
+	for lru.expireDue(expireBatch) == expireBatch && time.Now().Before(deadline) {
+	}
+}
+
+// expireDue removes at most n items that are due, past any grace window,
+// and returns how many it removed.
+func (lru *LRUCache[K, V]) expireDue(n int) int {
+	lru.lock()
+	defer lru.unlock()
+
+	now := time.Now()
+	removed := 0
+	for removed < n && len(lru.expiry) > 0 && lru.gone(lru.expiry[0], now) {
+		lru.removeExpired(lru.expiry[0])
+		removed++
+	}
+	return removed
+}
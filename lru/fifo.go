@@ -0,0 +1,46 @@
+package lru
+
+// fifoPolicy evicts the key that was inserted first. Reads and updates do
+// not reorder keys.
+type fifoPolicy[K comparable] struct {
+	list  *linkedList[K] // keys, newest at the front
+	elems map[K]*node[K]
+}
+
+// NewFIFOPolicy returns a first in, first out eviction policy, a cheap
+// option when recency accounting isn't worth its cost.
+func NewFIFOPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &fifoPolicy[K]{
+		list:  newLinkedList[K](),
+		elems: make(map[K]*node[K]),
+	}
+}
+
+func (p *fifoPolicy[K]) OnGet(key K) {}
+
+func (p *fifoPolicy[K]) OnSet(key K) {
+	if _, found := p.elems[key]; !found {
+		p.elems[key] = p.list.PushFront(key)
+	}
+}
+
+func (p *fifoPolicy[K]) OnRemove(key K) {
+	if elem, found := p.elems[key]; found {
+		p.list.Remove(elem)
+		delete(p.elems, key)
+	}
+}
+
+func (p *fifoPolicy[K]) Victim() (K, bool) {
+	if elem := p.list.Back(); elem != nil {
+		return elem.Value, true
+	}
+	var zero K
+	return zero, false
+}
+
+func (p *fifoPolicy[K]) walk(f func(key K)) {
+	for elem := p.list.Front(); elem != nil; elem = elem.Next() {
+		f(elem.Value)
+	}
+}
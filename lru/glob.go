@@ -0,0 +1,76 @@
+package lru
+
+// KeysMatching returns the non-expired keys of a string-keyed cache that
+// match the glob pattern, ordered from most to least recently used. In the
+// pattern, '*' matches any run of characters, '?' matches a single
+// character and '\' escapes the next character.
+func KeysMatching[K ~string, V any](lru *LRUCache[K, V], pattern string) []K {
+	keys := lru.Keys()
+	matched := keys[:0]
+	for _, key := range keys {
+		if matchGlob(pattern, string(key)) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// ScanMatching behaves like ScanEntries on a string-keyed cache but only
+// returns entries whose key matches the glob pattern, as in KeysMatching.
+// Like Redis' SCAN with MATCH, count bounds the entries examined rather
+// than returned, so pages may be short or even empty before the iteration
+// is complete.
+func ScanMatching[K ~string, V any](lru *LRUCache[K, V], cursor uint64, count int, pattern string) ([]Entry[K, V], uint64) {
+	entries, next := lru.ScanEntries(cursor, count)
+	matched := entries[:0]
+	for _, entry := range entries {
+		if matchGlob(pattern, string(entry.Key)) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched, next
+}
+
+// matchGlob reports whether s matches the glob pattern.
+func matchGlob(pattern, s string) bool {
+	p := []rune(pattern)
+	r := []rune(s)
+
+	// Backtracking matcher: on a mismatch, retry from the last '*' with
+	// one more character consumed by it.
+	pi, ri := 0, 0
+	starP, starR := -1, 0
+	for ri < len(r) {
+		if pi < len(p) {
+			switch c := p[pi]; {
+			case c == '*':
+				starP, starR = pi, ri
+				pi++
+				continue
+			case c == '?':
+				pi++
+				ri++
+				continue
+			case c == '\\' && pi+1 < len(p):
+				if p[pi+1] == r[ri] {
+					pi += 2
+					ri++
+					continue
+				}
+			case c == r[ri]:
+				pi++
+				ri++
+				continue
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starR++
+		pi, ri = starP+1, starR
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
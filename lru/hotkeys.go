@@ -0,0 +1,104 @@
+package lru
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// HotKey is a frequently read key together with an estimate of its recent
+// reads.
+type HotKey[K comparable] struct {
+	Key   K      `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// hotKeys tracks the most read keys of a cache. Reads are counted by a
+// count-min sketch, and the keys with the highest estimates are kept in a
+// small list; like the sketch's counters, their counts are halved
+// periodically so that keys which have cooled down drop out.
+type hotKeys[K comparable] struct {
+	sketch *countMinSketch[K, uint32]
+	top    []HotKey[K] // at most k keys, unordered
+	k      int
+}
+
+// WithHotKeys makes the cache track its k most read keys, reported by
+// HotKeys. Reads are counted approximately, with a count-min sketch, and
+// under heavy concurrency some reads may go uncounted. It panics if k < 1.
+func WithHotKeys(k int) Option {
+	if k < 1 {
+		panic(fmt.Sprintf("lru: hot key count %d less than 1", k))
+	}
+	return func(o *options) {
+		o.hotKeys = k
+	}
+}
+
+// newHotKeys returns a tracker of the k most read keys of a cache of the
+// given capacity.
+func newHotKeys[K comparable](capacity, k int) *hotKeys[K] {
+	return &hotKeys[K]{
+		sketch: newCountMinSketch[K, uint32](capacity, math.MaxUint32),
+		top:    make([]HotKey[K], 0, k),
+		k:      k,
+	}
+}
+
+// record counts a read of key.
+func (h *hotKeys[K]) record(key K) {
+	additions := h.sketch.additions
+	h.sketch.increment(key)
+	if h.sketch.additions < additions {
+		// The sketch was just halved.
+		for i := range h.top {
+			h.top[i].Count /= 2
+		}
+	}
+
+	count := uint64(h.sketch.estimate(key))
+	coldest := -1
+	for i, hot := range h.top {
+		if hot.Key == key {
+			h.top[i].Count = count
+			return
+		}
+		if coldest < 0 || hot.Count < h.top[coldest].Count {
+			coldest = i
+		}
+	}
+	switch {
+	case len(h.top) < h.k:
+		h.top = append(h.top, HotKey[K]{key, count})
+	case count > h.top[coldest].Count:
+		h.top[coldest] = HotKey[K]{key, count}
+	}
+}
+
+// HotKeys returns the most read keys, hottest first, in a cache created
+// with WithHotKeys, or nil otherwise. The keys may since have been
+// removed from the cache.
+func (lru *LRUCache[K, V]) HotKeys() []HotKey[K] {
+	lru.lock()
+	defer lru.unlock()
+
+	if lru.hot == nil {
+		return nil
+	}
+	keys := slices.Clone(lru.hot.top)
+	sortHotKeys(keys)
+	return keys
+}
+
+// sortHotKeys orders keys from the hottest to the coldest.
+func sortHotKeys[K comparable](keys []HotKey[K]) {
+	slices.SortStableFunc(keys, func(a, b HotKey[K]) int {
+		switch {
+		case a.Count > b.Count:
+			return -1
+		case a.Count < b.Count:
+			return 1
+		}
+		return 0
+	})
+}
@@ -0,0 +1,137 @@
+package lru
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// minAgePeriod is the fewest accesses between two agings of the LFU
+// frequency counts.
+const minAgePeriod = 1000
+
+// lfuPolicy evicts the least frequently used key, breaking ties by
+// evicting the least recently used one. Frequencies are halved every
+// agePeriod accesses so that keys which were hot long ago eventually
+// become evictable.
+type lfuPolicy[K comparable] struct {
+	entries   map[K]*lfuEntry[K]
+	heap      lfuHeap[K]
+	tick      uint64 // logical clock for recency tie-breaking
+	accesses  int    // accesses since the last aging
+	agePeriod int
+}
+
+// lfuEntry is the state lfuPolicy keeps per key.
+type lfuEntry[K comparable] struct {
+	key   K
+	freq  uint64
+	tick  uint64 // time of the last access
+	index int    // position in the heap
+}
+
+// NewLFUPolicy returns a least frequently used eviction policy with
+// frequency aging, which suits scan-heavy workloads better than recency
+// alone.
+func NewLFUPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &lfuPolicy[K]{
+		entries:   make(map[K]*lfuEntry[K]),
+		agePeriod: max(10*capacity, minAgePeriod),
+	}
+}
+
+func (p *lfuPolicy[K]) OnGet(key K) {
+	if e, found := p.entries[key]; found {
+		p.touch(e)
+	}
+}
+
+func (p *lfuPolicy[K]) OnSet(key K) {
+	if e, found := p.entries[key]; found {
+		p.touch(e)
+		return
+	}
+	p.tick++
+	e := &lfuEntry[K]{key: key, freq: 1, tick: p.tick}
+	p.entries[key] = e
+	heap.Push(&p.heap, e)
+}
+
+func (p *lfuPolicy[K]) OnRemove(key K) {
+	if e, found := p.entries[key]; found {
+		heap.Remove(&p.heap, e.index)
+		delete(p.entries, key)
+	}
+}
+
+func (p *lfuPolicy[K]) Victim() (K, bool) {
+	if len(p.heap) == 0 {
+		var zero K
+		return zero, false
+	}
+	return p.heap[0].key, true
+}
+
+func (p *lfuPolicy[K]) walk(f func(key K)) {
+	entries := make([]*lfuEntry[K], len(p.heap))
+	copy(entries, p.heap)
+	sort.Slice(entries, func(i, j int) bool {
+		return p.heap.less(entries[j], entries[i])
+	})
+	for _, e := range entries {
+		f(e.key)
+	}
+}
+
+// touch counts an access to e, aging all frequencies once agePeriod
+// accesses have accumulated.
+func (p *lfuPolicy[K]) touch(e *lfuEntry[K]) {
+	p.tick++
+	e.freq++
+	e.tick = p.tick
+	heap.Fix(&p.heap, e.index)
+
+	p.accesses++
+	if p.accesses >= p.agePeriod {
+		p.accesses = 0
+		for _, e := range p.heap {
+			e.freq = (e.freq + 1) / 2
+		}
+		// Halving can turn distinct frequencies into ties that the
+		// recency tie-break orders differently, so rebuild the heap.
+		heap.Init(&p.heap)
+	}
+}
+
+// lfuHeap is a min-heap of entries ordered by frequency, then recency.
+type lfuHeap[K comparable] []*lfuEntry[K]
+
+func (h lfuHeap[K]) less(a, b *lfuEntry[K]) bool {
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.tick < b.tick
+}
+
+func (h lfuHeap[K]) Len() int           { return len(h) }
+func (h lfuHeap[K]) Less(i, j int) bool { return h.less(h[i], h[j]) }
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x any) {
+	e := x.(*lfuEntry[K])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
@@ -0,0 +1,117 @@
+package lru
+
+// linkedList is a doubly linked list of values of type T. It mirrors
+// container/list, but stores values unboxed, so neither pushing a key nor
+// reading it back costs an interface conversion, and it can link nodes
+// embedded in other structs, which then need no allocation of their own.
+type linkedList[T any] struct {
+	root node[T] // sentinel: root.next is the front and root.prev the back
+	len  int
+}
+
+// node is an element of a linkedList.
+type node[T any] struct {
+	Value      T
+	prev, next *node[T]
+	list       *linkedList[T] // nil once removed
+}
+
+// newLinkedList returns an empty list.
+func newLinkedList[T any]() *linkedList[T] {
+	return new(linkedList[T]).Init()
+}
+
+// Init empties the list.
+func (l *linkedList[T]) Init() *linkedList[T] {
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	l.len = 0
+	return l
+}
+
+// Len returns the number of nodes in the list.
+func (l *linkedList[T]) Len() int {
+	return l.len
+}
+
+// Front returns the first node, or nil if the list is empty.
+func (l *linkedList[T]) Front() *node[T] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
+// Back returns the last node, or nil if the list is empty.
+func (l *linkedList[T]) Back() *node[T] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// Next returns the node after n, or nil if n is the last.
+func (n *node[T]) Next() *node[T] {
+	if next := n.next; n.list != nil && next != &n.list.root {
+		return next
+	}
+	return nil
+}
+
+// Prev returns the node before n, or nil if n is the first.
+func (n *node[T]) Prev() *node[T] {
+	if prev := n.prev; n.list != nil && prev != &n.list.root {
+		return prev
+	}
+	return nil
+}
+
+// PushFront inserts a new node holding v at the front and returns it.
+func (l *linkedList[T]) PushFront(v T) *node[T] {
+	return l.insert(&node[T]{Value: v}, &l.root)
+}
+
+// PushBack inserts a new node holding v at the back and returns it.
+func (l *linkedList[T]) PushBack(v T) *node[T] {
+	return l.insert(&node[T]{Value: v}, l.root.prev)
+}
+
+// InsertBefore inserts a new node holding v just before mark and returns
+// it.
+func (l *linkedList[T]) InsertBefore(v T, mark *node[T]) *node[T] {
+	return l.insert(&node[T]{Value: v}, mark.prev)
+}
+
+// Remove unlinks n, which must be on l, and returns its value.
+func (l *linkedList[T]) Remove(n *node[T]) T {
+	l.unlink(n)
+	n.next, n.prev, n.list = nil, nil, nil
+	return n.Value
+}
+
+// MoveToFront moves n, which must be on l, to the front.
+func (l *linkedList[T]) MoveToFront(n *node[T]) {
+	if l.root.next == n {
+		return
+	}
+	l.unlink(n)
+	l.insert(n, &l.root)
+}
+
+// insert links n after at and returns it.
+func (l *linkedList[T]) insert(n, at *node[T]) *node[T] {
+	n.prev = at
+	n.next = at.next
+	n.prev.next = n
+	n.next.prev = n
+	n.list = l
+	l.len++
+	return n
+}
+
+// unlink takes n out of the list without clearing its links.
+func (l *linkedList[T]) unlink(n *node[T]) {
+	n.prev.next = n.next
+	n.next.prev = n.prev
+	l.len--
+}
@@ -0,0 +1,159 @@
+package lru
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrLoaderPanicked is returned by GetOrLoad to callers that waited on a
+// loader which panicked.
+var ErrLoaderPanicked = errors.New("lru: loader panicked")
+
+// loadCall tracks a loader invocation that is in flight for a key.
+type loadCall[V any] struct {
+	wg    sync.WaitGroup
+	value V
+	err   error
+}
+
+// GetOrLoad returns the value for the key, calling loader to produce it on
+// a miss. A successfully loaded value is stored in the cache. Concurrent
+// callers missing on the same key share a single loader invocation and
+// receive its result. Loader errors are returned and nothing is cached.
+// Negative entries return ErrNegativeEntry without calling the loader, and
+// a closed cache returns ErrClosed. In a cache created with
+// WithStaleWhileRevalidate, expired values still in their grace window are
+// returned as described for GetOrLoadStale. In one created with
+// WithEarlyRefresh or WithRefreshAhead, values close to expiry are
+// reloaded early.
+func (lru *LRUCache[K, V]) GetOrLoad(key K, loader func(key K) (V, error)) (V, error) {
+	v, _, err := lru.GetOrLoadStale(key, loader)
+	return v, err
+}
+
+// GetOrLoadStale behaves like GetOrLoad but, in a cache created with
+// WithStaleWhileRevalidate, returns an expired value that is still within
+// its grace window straight away, with stale set, while loader refreshes
+// it in the background.
+func (lru *LRUCache[K, V]) GetOrLoadStale(key K, loader func(key K) (V, error)) (value V, stale bool, err error) {
+	lru.lock()
+	if lru.closed {
+		lru.unlock()
+		return value, false, ErrClosed
+	}
+	now := time.Now()
+	if item, found := lru.cache[key]; found && item.expired(now) && !lru.gone(item, now) && !item.negative {
+		value = item.value
+		lru.refreshAsync(key, loader)
+		lru.unlock()
+		return value, true, nil
+	}
+	v, result := lru.lookup(key)
+	switch {
+	case result == Hit && lru.refreshEarly(key, now):
+		// Refreshing early: the first caller reloads while the rest keep
+		// using the current value.
+		if _, loading := lru.loads[key]; loading {
+			lru.unlock()
+			return v, false, nil
+		}
+	case result == Hit:
+		if lru.refreshDue(key, now) {
+			lru.refreshAsync(key, loader)
+		}
+		lru.unlock()
+		return v, false, nil
+	case result == NegativeHit:
+		lru.unlock()
+		return v, false, ErrNegativeEntry
+	}
+	if call, found := lru.loads[key]; found {
+		lru.unlock()
+		call.wg.Wait()
+		return call.value, false, call.err
+	}
+	call := lru.startLoad(key)
+	lru.unlock()
+
+	lru.load(key, loader, call)
+	return call.value, false, call.err
+}
+
+// refreshAsync reloads key in the background unless a load of it is
+// already in flight. If loader panics, the panic is recovered, as there is
+// no caller to pass it to, and the current value is kept. The caller must
+// hold lru.mu.
+func (lru *LRUCache[K, V]) refreshAsync(key K, loader func(key K) (V, error)) {
+	if _, loading := lru.loads[key]; !loading {
+		call := lru.startLoad(key)
+		go func() {
+			// load has already released any waiters with ErrLoaderPanicked
+			defer func() { recover() }()
+			lru.load(key, loader, call)
+		}()
+	}
+}
+
+// startLoad registers a loader invocation for key. The caller must hold
+// lru.mu.
+func (lru *LRUCache[K, V]) startLoad(key K) *loadCall[V] {
+	call := &loadCall[V]{}
+	call.wg.Add(1)
+	lru.loads[key] = call
+	return call
+}
+
+// load runs loader for the registered call, stores a successfully loaded
+// value and releases the callers waiting on call. If loader panics, the
+// waiting callers get ErrLoaderPanicked and the panic goes on up the
+// caller's stack; if the cache was closed meanwhile, they get ErrClosed.
+func (lru *LRUCache[K, V]) load(key K, loader func(key K) (V, error), call *loadCall[V]) {
+	start := time.Now()
+	returned := false
+	defer func() {
+		if !returned {
+			var zero V
+			call.value, call.err = zero, ErrLoaderPanicked
+		}
+		lru.lock()
+		if call.err == nil && lru.closed {
+			var zero V
+			call.value, call.err = zero, ErrClosed
+		}
+		if call.err == nil {
+			if item := lru.set(key, call.value); item != nil {
+				item.delta = time.Since(start)
+			}
+		}
+		delete(lru.loads, key)
+		lru.unlock()
+		call.wg.Done()
+	}()
+
+	call.value, call.err = loader(key)
+	returned = true
+}
+
+// refreshEarly decides whether a GetOrLoad hit on key should reload the
+// value before it expires, using the XFetch algorithm: the closer the
+// entry is to expiry and the longer its loader took, the likelier an early
+// refresh. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) refreshEarly(key K, now time.Time) bool {
+	item := lru.cache[key]
+	if lru.beta == 0 || item.expireAt.IsZero() || item.delta == 0 {
+		return false
+	}
+	gap := time.Duration(float64(item.delta) * lru.beta * -math.Log(1-rand.Float64()))
+	return !now.Add(gap).Before(item.expireAt)
+}
+
+// refreshDue reports whether a GetOrLoad hit on key falls within the
+// refresh-ahead window before the entry's expiry. The caller must hold
+// lru.mu.
+func (lru *LRUCache[K, V]) refreshDue(key K, now time.Time) bool {
+	item := lru.cache[key]
+	return lru.ahead > 0 && !item.expireAt.IsZero() && item.expireAt.Sub(now) <= lru.ahead
+}
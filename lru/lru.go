@@ -0,0 +1,996 @@
+// Package lru implements a thread-safe cache with per-entry expiration.
+// Entries are evicted least recently used first unless another
+// EvictionPolicy is configured.
+package lru
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LRUCache struct represents the LRU cache. K is the key type and V the
+// value type stored in the cache.
+type LRUCache[K comparable, V any] struct {
+	capacity  int              // maximum entry count, or total weight with a weigher
+	weight    int64            // total weight of the entries
+	weigher   func(K, V) int64 // nil if every entry weighs 1
+	bytes     int64            // estimated size of the entries
+	maxBytes  int64            // bound on bytes, or 0 for none
+	batch     int              // least number of entries to evict on overflow
+	batchFrac float64          // least share of the capacity to evict on overflow
+	ttl       time.Duration    // default expiration applied by Set
+	interval  time.Duration    // time between cleanup sweeps
+	cleaning  bool             // whether the cleanup goroutine was started
+	heartbeat atomic.Int64     // when it last finished a sweep, in Unix nanoseconds
+	noCleanup bool             // whether it must not be
+	sliding   bool             // whether reads renew an entry's TTL
+	ttlJitter float64          // largest share by which written TTLs vary
+	maxIdle   time.Duration    // longest an entry may go unread, or 0
+	grace     time.Duration    // how long expired entries may be served stale
+	beta      float64          // XFetch eagerness of early refreshes, or 0
+	ahead     time.Duration    // refresh-ahead window before expiry, or 0
+	cache     map[K]*CacheItem[K, V]
+	newPolicy func(capacity int) EvictionPolicy[K]
+	policies  [numPriorities]EvictionPolicy[K] // one policy per priority tier
+	pinned    map[K]*CacheItem[K, V]           // pinned items, which policies do not track
+	loads     map[K]*loadCall[V]
+	version   uint64
+	expiry    expiryHeap[K, V] // items that can expire, soonest first
+	order     scanOrder[K, V]  // items in insertion order, for Scan
+	seq       uint64
+	stats     counters
+	window    window // recent hits and misses
+	noWindow  bool   // whether window is disabled
+	onEvict   func(K, V, EvictionReason)
+	onExpire  func(K, V)
+	admit     func(K, V) bool  // nil to admit every write
+	doorkeep  *bloomFilter[K]  // keys written once, or nil
+	expiredCh chan Entry[K, V] // nil unless WithExpiredChannel was given
+	evictions []eviction[K, V] // OnEvict calls to make once mu is released
+	items     sync.Pool        // removed items for reuse by newItem
+	reads     []readRing[K, V] // promotions buffered by readLookup
+	writes    chan write[K, V] // queued writes, or nil unless WithAsyncWrites
+	queued    chan struct{}    // signals applyWrites that writes were queued
+	hot       *hotKeys[K]      // most read keys, or nil unless WithHotKeys
+	done      chan struct{}    // closed by Close to stop the goroutines
+	closed    bool
+	mu        sync.RWMutex
+}
+
+// CacheItem represents an item in the cache
+type CacheItem[K comparable, V any] struct {
+	key         K
+	value       V
+	expireAt    time.Time     // earlier of deadline and the idle limit
+	deadline    time.Time     // when the entry's TTL lapses, or zero
+	ttl         time.Duration // lifetime deadline was computed from
+	delta       time.Duration // time GetOrLoad's loader took to produce value
+	createdAt   time.Time
+	accesses    atomic.Uint64
+	version     uint64
+	negative    bool
+	pinned      bool
+	priority    Priority
+	weight      int64
+	size        int64 // estimated size, if the cache has a maxBytes
+	seq         uint64
+	scanIndex   int // position in lru.order
+	expiryIndex int // position in the expiry heap, or -1
+}
+
+// ItemInfo describes a cache entry along with its metadata.
+type ItemInfo[V any] struct {
+	Value       V
+	ExpireAt    time.Time // zero if the entry never expires
+	CreatedAt   time.Time
+	AccessCount uint64
+}
+
+// defaultCleanupInterval is the time between cleanup sweeps unless
+// WithCleanupInterval sets another.
+const defaultCleanupInterval = time.Minute
+
+// NoExpiration is the TTL of an entry that never expires.
+const NoExpiration time.Duration = -1
+
+// expired reports whether the item has expired at the given time. Items
+// with a zero expireAt never expire.
+func (item *CacheItem[K, V]) expired(now time.Time) bool {
+	return !item.expireAt.IsZero() && now.After(item.expireAt)
+}
+
+// expireAfter returns the expiration time for an entry written now with
+// the given ttl, or the zero time for NoExpiration.
+func expireAfter(ttl time.Duration) time.Time {
+	if ttl == NoExpiration {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// NewLRUCache initializes a new LRUCache with a given capacity and expiration time
+// in seconds. An expireSec of 0 or less means entries never expire unless
+// written with a TTL; the cleanup goroutine is then only started once such
+// an entry is written.
+func NewLRUCache[K comparable, V any](capacity, expireSec int, opts ...Option) *LRUCache[K, V] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cache := &LRUCache[K, V]{
+		capacity:  capacity,
+		maxBytes:  o.maxBytes,
+		batch:     o.batch,
+		batchFrac: o.batchFrac,
+		ttl:       time.Duration(expireSec) * time.Second,
+		interval:  defaultCleanupInterval,
+		sliding:   o.sliding,
+		maxIdle:   o.maxIdle,
+		grace:     o.grace,
+		beta:      o.beta,
+		ahead:     o.ahead,
+		ttlJitter: o.jitter,
+		cache:     make(map[K]*CacheItem[K, V]),
+		newPolicy: NewLRUPolicy[K],
+		pinned:    make(map[K]*CacheItem[K, V]),
+		loads:     make(map[K]*loadCall[V]),
+		reads:     newReadRings[K, V](),
+		done:      make(chan struct{}),
+	}
+	if o.newPolicy != nil {
+		newPolicy, ok := o.newPolicy.(func(int) EvictionPolicy[K])
+		if !ok {
+			panic("lru: WithPolicy key type does not match the cache")
+		}
+		cache.newPolicy = newPolicy
+	}
+	if o.weigher != nil {
+		weigher, ok := o.weigher.(func(K, V) int64)
+		if !ok {
+			panic("lru: WithWeigher key or value type does not match the cache")
+		}
+		cache.weigher = weigher
+	}
+	if o.onEvict != nil {
+		onEvict, ok := o.onEvict.(func(K, V, EvictionReason))
+		if !ok {
+			panic("lru: WithOnEvict key or value type does not match the cache")
+		}
+		cache.onEvict = onEvict
+	}
+	if o.onExpire != nil {
+		onExpire, ok := o.onExpire.(func(K, V))
+		if !ok {
+			panic("lru: WithOnExpire key or value type does not match the cache")
+		}
+		cache.onExpire = onExpire
+	}
+	if o.admit != nil {
+		admit, ok := o.admit.(func(K, V) bool)
+		if !ok {
+			panic("lru: WithAdmit key or value type does not match the cache")
+		}
+		cache.admit = admit
+	}
+	if o.doorkeeper {
+		cache.doorkeep = newBloomFilter[K](capacity)
+	}
+	if o.expiredBuffer > 0 {
+		cache.expiredCh = make(chan Entry[K, V], o.expiredBuffer)
+	}
+	if o.hotKeys > 0 {
+		cache.hot = newHotKeys[K](capacity, o.hotKeys)
+	}
+	cache.resetPolicies()
+
+	if o.interval > 0 {
+		cache.interval = o.interval
+	}
+
+	if expireSec <= 0 {
+		cache.ttl = NoExpiration
+	}
+	cache.noCleanup = o.noCleanup
+	cache.noWindow = o.noHitRates
+	if cache.ttl != NoExpiration {
+		cache.startCleanup()
+	}
+	if o.pressure != nil {
+		go cache.watchMemory(o.pressure)
+	}
+	if o.writeBuffer > 0 {
+		cache.writes = make(chan write[K, V], o.writeBuffer)
+		cache.queued = make(chan struct{}, 1)
+		go cache.applyWrites()
+	}
+
+	return cache
+}
+
+// Get retrieves the value of the key if the key exists in the cache.
+// The boolean result reports whether the key was found. Hits on live
+// entries only take the read lock, so concurrent Gets do not block each
+// other; their promotions in the eviction order are applied in batches
+// and may be dropped under heavy load. Such hits do not allocate. Every
+// lookup reads the clock to count it in the hit-rate window behind
+// HitRates; in a cache created with WithoutHitRates, hits on entries
+// without an expiration skip the clock altogether.
+func (lru *LRUCache[K, V]) Get(key K) (V, bool) {
+	if v, result, ok := lru.readLookup(key); ok {
+		return v, result == Hit
+	}
+
+	lru.lock()
+	defer lru.unlock()
+
+	return lru.get(key)
+}
+
+// get is Get without locking. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) get(key K) (V, bool) {
+	v, result := lru.lookup(key)
+	return v, result == Hit
+}
+
+// lookup is Lookup without locking. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) lookup(key K) (V, LookupResult) {
+	var zero V
+	now := time.Now()
+	if item, found := lru.cache[key]; found {
+		if item.expired(now) {
+			// Remove expired item from cache, unless it may still be
+			// served stale
+			if lru.gone(item, now) {
+				lru.removeExpired(item)
+			}
+			lru.recordMiss(now)
+			return zero, Miss
+		}
+		if !item.pinned {
+			lru.policies[item.priority].OnGet(key)
+		}
+		if lru.sliding && !item.deadline.IsZero() {
+			item.deadline = now.Add(item.ttl)
+		}
+		if lru.sliding || lru.maxIdle > 0 {
+			item.expireAt = lru.expiresAt(item.deadline, now)
+			lru.schedule(item)
+		}
+		item.accesses.Add(1)
+		if lru.hot != nil {
+			lru.hot.record(key)
+		}
+		lru.recordHit(now)
+		if item.negative {
+			return zero, NegativeHit
+		}
+		return item.value, Hit
+	}
+	lru.recordMiss(now)
+	return zero, Miss
+}
+
+// GetWithInfo behaves like Get but also returns the entry's metadata.
+func (lru *LRUCache[K, V]) GetWithInfo(key K) (ItemInfo[V], bool) {
+	lru.lock()
+	defer lru.unlock()
+
+	if _, found := lru.get(key); !found {
+		return ItemInfo[V]{}, false
+	}
+	item := lru.cache[key]
+	return ItemInfo[V]{
+		Value:       item.value,
+		ExpireAt:    item.expireAt,
+		CreatedAt:   item.createdAt,
+		AccessCount: item.accesses.Load(),
+	}, true
+}
+
+// MGet retrieves the values of several keys under a single lock
+// acquisition. Keys that are missing or expired are omitted from the
+// result.
+func (lru *LRUCache[K, V]) MGet(keys []K) map[K]V {
+	lru.lock()
+	defer lru.unlock()
+
+	values := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if v, found := lru.get(key); found {
+			values[key] = v
+		}
+	}
+	return values
+}
+
+// Peek returns the value of the key without counting as an access, so the
+// eviction order is left unchanged. Expired items are reported as missing
+// but are left for cleanup to remove.
+func (lru *LRUCache[K, V]) Peek(key K) (V, bool) {
+	entry, found := lru.peekEntry(key)
+	return entry.Value, found
+}
+
+// peekEntry is Peek returning the entry's expiration along with its value.
+func (lru *LRUCache[K, V]) peekEntry(key K) (Entry[K, V], bool) {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	if item, found := lru.cache[key]; found {
+		if item.expired(time.Now()) || item.negative {
+			return Entry[K, V]{}, false
+		}
+		return Entry[K, V]{item.key, item.value, item.expireAt}, true
+	}
+	return Entry[K, V]{}, false
+}
+
+// TTL returns the remaining lifetime of the key, or NoExpiration if the
+// key never expires. The boolean result is false if the key is missing or
+// expired. Like Peek, it does not count as an access.
+func (lru *LRUCache[K, V]) TTL(key K) (time.Duration, bool) {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	if item, found := lru.cache[key]; found {
+		if item.expireAt.IsZero() {
+			return NoExpiration, true
+		}
+		remaining := time.Until(item.expireAt)
+		if remaining < 0 {
+			return 0, false
+		}
+		return remaining, true
+	}
+	return 0, false
+}
+
+// Contains reports whether the key is present and not expired. Like
+// Peek, it does not count as an access.
+func (lru *LRUCache[K, V]) Contains(key K) bool {
+	_, found := lru.Peek(key)
+	return found
+}
+
+// Len returns the number of live (non-expired) entries in the cache.
+func (lru *LRUCache[K, V]) Len() int {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	now := time.Now()
+	n := 0
+	for _, item := range lru.cache {
+		if !item.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Weight returns the total weight of the entries in the cache, including
+// expired ones not yet removed. Without WithWeigher every entry weighs 1.
+func (lru *LRUCache[K, V]) Weight() int64 {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	return lru.weight
+}
+
+// Bytes returns the estimated memory held by the entries in the cache. It
+// is only tracked for caches created with WithMaxBytes and is 0 otherwise.
+func (lru *LRUCache[K, V]) Bytes() int64 {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	return lru.bytes
+}
+
+// MaxBytes returns the memory budget set with WithMaxBytes, or 0 if there
+// is none.
+func (lru *LRUCache[K, V]) MaxBytes() int64 {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	return lru.maxBytes
+}
+
+// Cap returns the configured capacity of the cache.
+func (lru *LRUCache[K, V]) Cap() int {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	return lru.capacity
+}
+
+// Resize changes the capacity of the cache, a total weight if the cache
+// was created with WithWeigher. When shrinking, entries are evicted as
+// they would be by Set until the cache fits. It returns the number of
+// entries evicted.
+func (lru *LRUCache[K, V]) Resize(capacity int) int {
+	lru.lock()
+	defer lru.unlock()
+
+	lru.capacity = capacity
+	for _, policy := range lru.policies {
+		if r, ok := policy.(resizer); ok {
+			r.resize(capacity)
+		}
+	}
+	evicted := 0
+	for lru.overflowed() && lru.evict() {
+		evicted++
+	}
+	return evicted
+}
+
+// Keys returns a snapshot of the non-expired keys in the cache, ordered
+// by priority, highest first. Within a priority, pinned keys come first,
+// followed by the rest from the most to the least worth keeping according
+// to the eviction policy; for the default policy that is from most to
+// least recently used. Custom policies leave this order unspecified.
+func (lru *LRUCache[K, V]) Keys() []K {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]K, 0, len(lru.cache))
+	lru.walk(func(item *CacheItem[K, V]) {
+		if !item.expired(now) {
+			keys = append(keys, item.key)
+		}
+	})
+	return keys
+}
+
+// Entry is a snapshot of a single cache entry.
+type Entry[K comparable, V any] struct {
+	Key      K
+	Value    V
+	ExpireAt time.Time // zero if the entry never expires
+}
+
+// Entries returns a snapshot of all non-expired entries in the cache,
+// in the same order as Keys, taken under a single lock acquisition.
+// Negative entries are skipped.
+func (lru *LRUCache[K, V]) Entries() []Entry[K, V] {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	now := time.Now()
+	entries := make([]Entry[K, V], 0, len(lru.cache))
+	lru.walk(func(item *CacheItem[K, V]) {
+		if !item.expired(now) && !item.negative {
+			entries = append(entries, Entry[K, V]{item.key, item.value, item.expireAt})
+		}
+	})
+	return entries
+}
+
+// walk calls f for every item in the order described by Keys. The caller
+// must hold lru.mu.
+func (lru *LRUCache[K, V]) walk(f func(item *CacheItem[K, V])) {
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		for _, item := range lru.pinned {
+			if item.priority == p {
+				f(item)
+			}
+		}
+		if w, ok := lru.policies[p].(walker[K]); ok {
+			w.walk(func(key K) { f(lru.cache[key]) })
+			continue
+		}
+		for _, item := range lru.cache {
+			if item.priority == p && !item.pinned {
+				f(item)
+			}
+		}
+	}
+}
+
+// Range calls f for each non-expired entry in the cache, in the same order
+// as Keys, until f returns false. Range iterates over a snapshot taken
+// with Entries, so f may safely call other cache methods.
+// Entries that never expire are passed a zero expireAt, and negative
+// entries are skipped.
+func (lru *LRUCache[K, V]) Range(f func(key K, value V, expireAt time.Time) bool) {
+	for _, entry := range lru.Entries() {
+		if !f(entry.Key, entry.Value, entry.ExpireAt) {
+			return
+		}
+	}
+}
+
+// Set updates the value of the key if the key exists in the cache,
+// otherwise inserts the key-value pair into the cache. If the cache
+// reaches its capacity, it evicts the entry chosen by the eviction
+// policy, by default the least recently used one. In a cache created with
+// WithAsyncWrites, the write is queued rather than applied.
+func (lru *LRUCache[K, V]) Set(key K, value V) {
+	if lru.writes != nil {
+		lru.enqueue(write[K, V]{key: key, value: value, defaultTTL: true})
+		return
+	}
+
+	lru.lock()
+	defer lru.unlock()
+
+	lru.set(key, value)
+}
+
+// SetWithTTL behaves like Set but expires the entry after ttl instead of
+// the cache's default expiration time. A ttl of NoExpiration keeps the
+// entry until it is evicted or deleted. Like Set, it is queued in a cache
+// created with WithAsyncWrites.
+func (lru *LRUCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	if lru.writes != nil {
+		lru.enqueue(write[K, V]{key: key, value: value, ttl: ttl})
+		return
+	}
+
+	lru.lock()
+	defer lru.unlock()
+
+	lru.setTTL(key, value, ttl)
+}
+
+// SetWithDeadline behaves like Set but expires the entry at the given
+// wall-clock time, such as the end of the day, instead of after a TTL. The
+// deadline is not jittered. A zero deadline keeps the entry until it is
+// evicted or deleted, and a deadline in the past stores an entry that has
+// already expired.
+func (lru *LRUCache[K, V]) SetWithDeadline(key K, value V, deadline time.Time) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	ttl := NoExpiration
+	if !deadline.IsZero() {
+		ttl = time.Until(deadline)
+	}
+	lru.store(key, value, deadline, ttl, keepPriority)
+}
+
+// set is Set without locking. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) set(key K, value V) *CacheItem[K, V] {
+	return lru.setTTL(key, value, lru.ttl)
+}
+
+// setTTL is SetWithTTL without locking. It returns the stored item, or nil
+// if the cache is closed, the entry outweighs the whole capacity or memory
+// budget, was vetoed by the admit hook or the doorkeeper, or the eviction
+// policy evicted it straight away. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) setTTL(key K, value V, ttl time.Duration) *CacheItem[K, V] {
+	ttl = lru.jitter(ttl)
+	return lru.store(key, value, expireAfter(ttl), ttl, keepPriority)
+}
+
+// store is setTTL for an entry whose TTL lapses at deadline, or never if
+// that is zero, having been given the lifetime ttl. The entry is placed in
+// the priority tier before room is made for it, unless priority is
+// keepPriority. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) store(key K, value V, deadline time.Time, ttl time.Duration, priority Priority) *CacheItem[K, V] {
+	now := time.Now()
+	if lru.closed {
+		return nil
+	}
+	lru.version++
+	lru.stats.sets.Add(1)
+	weight := lru.weigh(key, value)
+	var size int64
+	if lru.maxBytes > 0 {
+		size = entrySize(key, value)
+	}
+	item, found := lru.cache[key]
+	tooBig := weight > int64(lru.capacity) || lru.maxBytes > 0 && size > lru.maxBytes
+	if tooBig || lru.admit != nil && !lru.admit(key, value) {
+		// Storing an entry that can never fit would only flush the cache.
+		// The old value is dropped either way so that it is not served
+		// in place of the new one.
+		if found {
+			lru.removeItem(item, ReasonReplaced)
+		}
+		return nil
+	}
+	if !found && lru.doorkeep != nil && !lru.doorkeep.testAndAdd(key) {
+		// First write of the key: only remember that it was seen.
+		return nil
+	}
+	if found {
+		if item.expired(now) {
+			lru.notify(item, ReasonExpired)
+		} else {
+			lru.notify(item, ReasonReplaced)
+		}
+		item.value = value
+		item.deadline = deadline
+		item.expireAt = lru.expiresAt(deadline, now)
+		item.ttl = ttl
+		lru.schedule(item)
+		item.version = lru.version
+		item.negative = false
+		lru.weight += weight - item.weight
+		item.weight = weight
+		lru.bytes += size - item.size
+		item.size = size
+		if priority != keepPriority && priority != item.priority {
+			if !item.pinned {
+				lru.policies[item.priority].OnRemove(key)
+			}
+			item.priority = priority
+		}
+		if !item.pinned {
+			lru.policies[item.priority].OnSet(key)
+		}
+	} else {
+		if priority == keepPriority {
+			priority = PriorityNormal
+		}
+		lru.seq++
+		item = lru.newItem()
+		*item = CacheItem[K, V]{
+			key:         key,
+			value:       value,
+			expireAt:    lru.expiresAt(deadline, now),
+			deadline:    deadline,
+			ttl:         ttl,
+			createdAt:   now,
+			version:     lru.version,
+			priority:    priority,
+			weight:      weight,
+			size:        size,
+			seq:         lru.seq,
+			expiryIndex: -1,
+		}
+		lru.schedule(item)
+		lru.order.push(item)
+		lru.cache[key] = item
+		lru.weight += weight
+		lru.bytes += size
+		lru.policies[item.priority].OnSet(key)
+	}
+	lru.makeRoom()
+	return lru.cache[key]
+}
+
+// DefaultTTL returns the expiration time applied by Set, or NoExpiration.
+func (lru *LRUCache[K, V]) DefaultTTL() time.Duration {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	return lru.ttl
+}
+
+// SetDefaultTTL changes the expiration time applied by Set to entries
+// written from now on; NoExpiration makes them never expire. Existing
+// entries keep their expiration. SetDefaultTTL panics if ttl is neither
+// positive nor NoExpiration.
+func (lru *LRUCache[K, V]) SetDefaultTTL(ttl time.Duration) {
+	if ttl <= 0 && ttl != NoExpiration {
+		panic("lru: non-positive default TTL")
+	}
+
+	lru.lock()
+	defer lru.unlock()
+
+	lru.ttl = ttl
+}
+
+// MSet stores all the given key-value pairs under a single lock
+// acquisition.
+func (lru *LRUCache[K, V]) MSet(items map[K]V) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	for key, value := range items {
+		lru.set(key, value)
+	}
+}
+
+// GetOrSet returns the existing value for the key if present and not
+// expired. Otherwise it stores the given value and returns it. The loaded
+// result is true if the value was already in the cache.
+func (lru *LRUCache[K, V]) GetOrSet(key K, value V) (actual V, loaded bool) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	if v, found := lru.get(key); found {
+		return v, true
+	}
+	lru.set(key, value)
+	return value, false
+}
+
+// SetIfAbsent stores the value under the key with the given ttl, as in
+// SetWithTTL, only if the key is missing, expired or negative. It reports
+// whether the value was stored.
+func (lru *LRUCache[K, V]) SetIfAbsent(key K, value V, ttl time.Duration) bool {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	if lru.live(key) {
+		return false
+	}
+	return lru.setTTL(key, value, ttl) != nil
+}
+
+// SetIfPresent stores the value under the key with the given ttl, as in
+// SetWithTTL, only if the key already holds a live value. It reports
+// whether the value was stored.
+func (lru *LRUCache[K, V]) SetIfPresent(key K, value V, ttl time.Duration) bool {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	if !lru.live(key) {
+		return false
+	}
+	return lru.setTTL(key, value, ttl) != nil
+}
+
+// live reports whether the key holds a non-expired, non-negative value
+// without counting as an access. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) live(key K) bool {
+	item, found := lru.cache[key]
+	return found && !item.expired(time.Now()) && !item.negative
+}
+
+// Delete removes the key from the cache. It reports whether the key
+// was present.
+func (lru *LRUCache[K, V]) Delete(key K) bool {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	item, found := lru.cache[key]
+	if !found {
+		return false
+	}
+	lru.removeItem(item, ReasonDeleted)
+	return true
+}
+
+// GetDel atomically retrieves and removes the key, so that no two callers
+// can both observe the same value. The boolean result reports whether a
+// live value was found.
+func (lru *LRUCache[K, V]) GetDel(key K) (V, bool) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	v, found := lru.get(key)
+	if found {
+		lru.removeItem(lru.cache[key], ReasonDeleted)
+	}
+	return v, found
+}
+
+// Clear removes all entries from the cache.
+func (lru *LRUCache[K, V]) Clear() {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	lru.removeAll()
+}
+
+// removeAll is Clear without locking. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) removeAll() {
+	for _, item := range lru.cache {
+		lru.notify(item, ReasonDeleted)
+	}
+	lru.cache = make(map[K]*CacheItem[K, V])
+	lru.pinned = make(map[K]*CacheItem[K, V])
+	lru.expiry = nil
+	lru.weight = 0
+	lru.bytes = 0
+	lru.resetPolicies()
+	lru.order = scanOrder[K, V]{}
+}
+
+// RemoveOldest removes the entry that would be evicted next, the eviction
+// policy's victim among the unpinned entries of the lowest priority, and
+// returns it. With the default policy that is the least recently used
+// entry. Expired entries found along the way are discarded. The boolean
+// result is false if the cache has no live unpinned entries.
+func (lru *LRUCache[K, V]) RemoveOldest() (key K, value V, ok bool) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	now := time.Now()
+	for item := lru.victim(); item != nil; item = lru.victim() {
+		if item.expired(now) {
+			lru.removeExpired(item)
+			continue
+		}
+		key, value = item.key, item.value
+		lru.removeItem(item, ReasonDeleted)
+		return key, value, true
+	}
+	return key, value, false
+}
+
+// Touch resets the expiration of the key to the cache's default
+// expiration time from now. It reports whether the key was present.
+func (lru *LRUCache[K, V]) Touch(key K) bool {
+	return lru.Expire(key, lru.DefaultTTL())
+}
+
+// Expire sets a new ttl on the key, counted from now. A ttl of
+// NoExpiration is equivalent to Persist. It reports whether the key was
+// present.
+func (lru *LRUCache[K, V]) Expire(key K, ttl time.Duration) bool {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	now := time.Now()
+	item, found := lru.cache[key]
+	if !found || item.expired(now) {
+		return false
+	}
+	item.deadline = expireAfter(ttl)
+	item.expireAt = lru.expiresAt(item.deadline, now)
+	item.ttl = ttl
+	lru.schedule(item)
+	return true
+}
+
+// Persist removes the expiration of the key so it is kept until it is
+// evicted or deleted. It reports whether the key was present.
+func (lru *LRUCache[K, V]) Persist(key K) bool {
+	return lru.Expire(key, NoExpiration)
+}
+
+// weigh returns the weight of an entry. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) weigh(key K, value V) int64 {
+	if lru.weigher == nil {
+		return 1
+	}
+	return lru.weigher(key, value)
+}
+
+// overflowed reports whether the entries exceed the capacity or the
+// memory budget. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) overflowed() bool {
+	return lru.weight > int64(lru.capacity) || lru.maxBytes > 0 && lru.bytes > lru.maxBytes
+}
+
+// makeRoom evicts entries until the cache fits its capacity and memory
+// budget. If any eviction is needed, at least a full eviction batch is
+// evicted. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) makeRoom() {
+	if !lru.overflowed() {
+		return
+	}
+	batch := max(lru.batch, int(lru.batchFrac*float64(lru.capacity)))
+	for evicted := 0; (lru.overflowed() || evicted < batch) && lru.evict(); evicted++ {
+	}
+}
+
+// evict removes the eviction policy's victim from the lowest priority tier
+// that has one. Pinned items are never evicted. A victim that has already
+// expired is counted as an expiration rather than an eviction. It reports
+// whether an entry was removed. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) evict() bool {
+	item := lru.victim()
+	if item == nil {
+		return false
+	}
+	if item.expired(time.Now()) {
+		lru.removeExpired(item)
+		return true
+	}
+	lru.removeItem(item, ReasonCapacity)
+	lru.stats.evictions.Add(1)
+	return true
+}
+
+// victim returns the item evict would remove, or nil if every item is
+// pinned. The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) victim() *CacheItem[K, V] {
+	for p := PriorityLow; p <= PriorityHigh; p++ {
+		if key, ok := lru.policies[p].Victim(); ok {
+			return lru.cache[key]
+		}
+	}
+	return nil
+}
+
+// resetPolicies replaces the eviction policies with fresh instances. The
+// caller must hold lru.mu or have exclusive access to the cache.
+func (lru *LRUCache[K, V]) resetPolicies() {
+	for p := range lru.policies {
+		lru.policies[p] = lru.newPolicy(lru.capacity)
+	}
+}
+
+// removeItem unlinks item from the cache and its eviction policy, queues
+// the OnEvict call for the given reason and recycles item. The caller must
+// hold lru.mu and must not use item afterwards.
+func (lru *LRUCache[K, V]) removeItem(item *CacheItem[K, V], reason EvictionReason) {
+	lru.notify(item, reason)
+	delete(lru.cache, item.key)
+	lru.weight -= item.weight
+	lru.bytes -= item.size
+	if item.pinned {
+		delete(lru.pinned, item.key)
+	} else {
+		lru.policies[item.priority].OnRemove(item.key)
+	}
+	lru.order.remove(item)
+	lru.unschedule(item)
+	lru.recycle(item)
+}
+
+// removeExpired removes an expired item and counts the expiration. The
+// caller must hold lru.mu.
+func (lru *LRUCache[K, V]) removeExpired(item *CacheItem[K, V]) {
+	lru.removeItem(item, ReasonExpired)
+	lru.stats.expirations.Add(1)
+}
+
+// startCleanup starts the cleanup goroutine unless it is running or
+// disabled. The caller must hold lru.mu or have exclusive access to the
+// cache.
+func (lru *LRUCache[K, V]) startCleanup() {
+	if lru.cleaning || lru.noCleanup {
+		return
+	}
+	lru.cleaning = true
+	lru.heartbeat.Store(time.Now().UnixNano())
+	go lru.cleanup()
+}
+
+// cleanup periodically removes expired items from the cache until it is
+// closed
+func (lru *LRUCache[K, V]) cleanup() {
+	for lru.sleep(lru.interval) {
+		lru.expireCycle()
+		lru.heartbeat.Store(time.Now().UnixNano())
+	}
+}
+
+// CleanupAlive reports whether the cleanup goroutine is keeping up: it
+// returns false if the goroutine has not finished a sweep for two cleanup
+// intervals, which would let expired entries pile up, or if the cache is
+// closed. A cache whose cleanup was never started, because nothing in it
+// can expire or WithoutCleanup was given, reports true.
+func (lru *LRUCache[K, V]) CleanupAlive() bool {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	if lru.closed {
+		return false
+	}
+	if !lru.cleaning {
+		return true
+	}
+	last := time.Unix(0, lru.heartbeat.Load())
+	return time.Since(last) <= 2*lru.interval+expireBudget
+}
+
+// jitter returns ttl varied at random by up to the configured jitter
+// fraction. NoExpiration is returned unchanged.
+func (lru *LRUCache[K, V]) jitter(ttl time.Duration) time.Duration {
+	if lru.ttlJitter == 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((2*rand.Float64()-1)*lru.ttlJitter*float64(ttl))
+}
+
+// expiresAt returns when an entry whose TTL lapses at deadline and that
+// was last accessed at now expires, taking the max-idle limit into
+// account. The zero time means never.
+func (lru *LRUCache[K, V]) expiresAt(deadline, now time.Time) time.Time {
+	if lru.maxIdle == 0 {
+		return deadline
+	}
+	idle := now.Add(lru.maxIdle)
+	if deadline.IsZero() || idle.Before(deadline) {
+		return idle
+	}
+	return deadline
+}
+
+// gone reports whether item has expired and is past the grace window in
+// which it may be served stale, so that it can be removed.
+func (lru *LRUCache[K, V]) gone(item *CacheItem[K, V], now time.Time) bool {
+	return item.expired(now.Add(-lru.grace))
+}
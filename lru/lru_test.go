@@ -0,0 +1,445 @@
+package lru
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadLoaderPanic(t *testing.T) {
+	c := NewLRUCache[string, int](10, 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	recovered := make(chan any)
+	go func() {
+		defer func() { recovered <- recover() }()
+		c.GetOrLoad("k", func(string) (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waited := make(chan error)
+	go func() {
+		_, err := c.GetOrLoad("k", func(string) (int, error) { return 1, nil })
+		waited <- err
+	}()
+	close(release)
+
+	if r := <-recovered; r != "boom" {
+		t.Errorf("loader's caller recovered %v, want boom", r)
+	}
+	if err := <-waited; err != nil && !errors.Is(err, ErrLoaderPanicked) {
+		t.Errorf("waiting caller got %v, want ErrLoaderPanicked or a fresh load", err)
+	}
+	if v, err := c.GetOrLoad("k", func(string) (int, error) { return 2, nil }); err != nil || v == 0 {
+		t.Errorf("GetOrLoad after a panic = %d, %v", v, err)
+	}
+}
+
+func TestBackgroundRefreshLoaderPanic(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opt  Option
+		ttl  time.Duration
+	}{
+		{"refresh ahead", WithRefreshAhead(time.Hour), time.Minute},
+		{"stale while revalidate", WithStaleWhileRevalidate(time.Hour), time.Nanosecond},
+	} {
+		c := NewLRUCache[string, int](10, 0, tt.opt)
+		c.SetWithTTL("k", 1, tt.ttl)
+		time.Sleep(time.Millisecond)
+
+		v, err := c.GetOrLoad("k", func(string) (int, error) { panic("boom") })
+		if v != 1 || err != nil {
+			t.Errorf("%s: GetOrLoad = %d, %v, want the current value", tt.name, v, err)
+		}
+		var item *CacheItem[string, int]
+		for loading := true; loading; {
+			time.Sleep(time.Millisecond)
+			c.lock()
+			_, loading = c.loads["k"]
+			item = c.cache["k"]
+			c.unlock()
+		}
+		if item == nil || item.value != 1 {
+			t.Errorf("%s: entry after the refresh panicked = %v, want the value 1 kept", tt.name, item)
+		}
+	}
+}
+
+func TestIncrNewVersion(t *testing.T) {
+	var replaced []int
+	c := NewLRUCache[string, int](10, 0, WithOnEvict(func(key string, value int, reason EvictionReason) {
+		if reason == ReasonReplaced {
+			replaced = append(replaced, value)
+		}
+	}))
+	c.Set("n", 10)
+	_, version, _ := c.GetVersion("n")
+	if got, _ := Incr(c, "n", 1); got != 11 {
+		t.Fatalf("Incr = %d, want 11", got)
+	}
+	if _, ok := c.CompareAndSwap("n", version, 100); ok {
+		t.Error("CompareAndSwap with the version read before Incr succeeded")
+	}
+	if v, _ := c.Get("n"); v != 11 {
+		t.Errorf("value = %d, want 11", v)
+	}
+	if len(replaced) != 1 || replaced[0] != 10 {
+		t.Errorf("replaced values = %v, want [10]", replaced)
+	}
+}
+
+func TestSetWithPriorityEvictsLowerTier(t *testing.T) {
+	c := NewLRUCache[string, int](2, 0)
+	c.Set("n1", 1)
+	c.Set("n2", 2)
+	c.SetWithPriority("low", 3, PriorityLow)
+	if _, found := c.Get("low"); found {
+		t.Error("low-priority entry kept in a cache full of normal ones")
+	}
+	for _, key := range []string{"n1", "n2"} {
+		if _, found := c.Get(key); !found {
+			t.Errorf("%s evicted for a low-priority entry", key)
+		}
+	}
+
+	high := PriorityHigh
+	c.SetWithOptions("high", 4, SetOptions{Priority: &high})
+	if _, found := c.Get("high"); !found {
+		t.Error("high-priority entry evicted")
+	}
+}
+
+func TestPriorityOutOfRange(t *testing.T) {
+	c := NewLRUCache[string, int](2, 0)
+	c.SetWithPriority("high", 1, Priority(7))
+	c.SetWithPriority("low", 2, Priority(-3))
+	above := Priority(100)
+	c.SetWithOptions("n", 3, SetOptions{Priority: &above})
+	if _, found := c.Get("high"); !found {
+		t.Error("entry above PriorityHigh evicted before a low one")
+	}
+	if _, found := c.Get("low"); found {
+		t.Error("entry below PriorityLow kept over a high one")
+	}
+	if !c.SetPriority("n", Priority(-1)) {
+		t.Error("SetPriority below PriorityLow failed")
+	}
+	c.Set("m", 4)
+	if _, found := c.Get("n"); found {
+		t.Error("entry moved below PriorityLow kept over a normal one")
+	}
+}
+
+func TestGetHitDoesNotAllocate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		ttl  int
+	}{
+		{"default", nil, 0},
+		{"ttl", nil, 60},
+		{"without hit rates", []Option{WithoutHitRates()}, 0},
+	} {
+		c := NewLRUCache[string, int](100, tt.ttl, tt.opts...)
+		c.Set("k", 1)
+		if allocs := testing.AllocsPerRun(1000, func() { c.Get("k") }); allocs != 0 {
+			t.Errorf("%s: Get hit allocates %v times", tt.name, allocs)
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		opts []Option
+		ttl  int
+	}{
+		{"default", nil, 0},
+		{"ttl", nil, 60},
+		{"without hit rates", []Option{WithoutHitRates()}, 0},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			c := NewLRUCache[string, int](1024, bm.ttl, bm.opts...)
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = strconv.Itoa(i)
+				c.Set(keys[i], i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					c.Get(keys[i%len(keys)])
+				}
+			})
+		})
+	}
+}
+
+func TestScanResumesAfterRemovedCursor(t *testing.T) {
+	const n = 1000
+	cache := NewLRUCache[int, int](n, 0)
+	for i := range n {
+		cache.Set(i, i)
+	}
+
+	seen := make(map[int]int)
+	var cursor uint64
+	for {
+		var keys []int
+		keys, cursor = cache.Scan(cursor, 5)
+		for _, k := range keys {
+			seen[k]++
+		}
+		if cursor == 0 {
+			break
+		}
+		// Remove the key at the cursor and the next few, enough for the
+		// scan order to be compacted along the way
+		last := keys[len(keys)-1]
+		for k := last; k < last+8 && k < n; k++ {
+			cache.Delete(k)
+		}
+	}
+
+	if len(cache.order.slots) == n {
+		t.Errorf("scan order was never compacted")
+	}
+	for k := range n {
+		if _, present := cache.Peek(k); present && seen[k] != 1 {
+			t.Errorf("key %d present throughout was returned %d times", k, seen[k])
+		}
+	}
+	for k, times := range seen {
+		if times != 1 {
+			t.Errorf("key %d returned %d times", k, times)
+		}
+	}
+}
+
+// checkResident fails t unless exactly the keys marked true in want are in c.
+func checkResident(t *testing.T, c *LRUCache[string, int], want map[string]bool) {
+	t.Helper()
+	for key, resident := range want {
+		if c.Contains(key) != resident {
+			t.Errorf("Contains(%q) = %v, want %v", key, !resident, resident)
+		}
+	}
+}
+
+func TestARCScanKeepsFrequentKeys(t *testing.T) {
+	c := NewLRUCache[string, int](4, 0, WithPolicy(NewARCPolicy[string]))
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Get("b")
+	for i := range 10 {
+		c.Set("scan"+strconv.Itoa(i), i)
+	}
+	checkResident(t, c, map[string]bool{"a": true, "b": true, "scan0": false, "scan9": true})
+}
+
+func TestTwoQOneHitKeysLeaveProbation(t *testing.T) {
+	c := NewLRUCache[string, int](4, 0, WithPolicy(NewTwoQPolicy[string]))
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		c.Set(key, i)
+	}
+	// a was evicted from the probationary queue and is now a ghost, so
+	// setting it again promotes it to the protected queue
+	c.Set("a", 0)
+	for i := range 10 {
+		c.Set("scan"+strconv.Itoa(i), i)
+	}
+	checkResident(t, c, map[string]bool{"a": true, "b": false, "e": false, "scan0": false, "scan9": true})
+}
+
+func TestSLRUProtectedRatio(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		newPolicy func(capacity int) EvictionPolicy[string]
+		aKept     bool
+	}{
+		// Reading a, b and c promotes all three, but only two fit the
+		// protected segment, so a drops back to probation
+		{"half protected", NewSLRUPolicyWithRatio[string](0.5), false},
+		{"default", NewSLRUPolicy[string], true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRUCache[string, int](4, 0, WithPolicy(tt.newPolicy))
+			for i, key := range []string{"a", "b", "c", "d"} {
+				c.Set(key, i)
+			}
+			c.Get("a")
+			c.Get("b")
+			c.Get("c")
+			c.Set("e", 4)
+			c.Set("f", 5)
+			checkResident(t, c, map[string]bool{"a": tt.aKept, "b": true, "c": true, "d": false})
+		})
+	}
+}
+
+func TestTinyLFURejectsColdCandidate(t *testing.T) {
+	// With a capacity of 2 the window and the main area hold one key each
+	c := NewLRUCache[string, int](2, 0, WithPolicy(NewTinyLFUPolicy[string]))
+	c.Set("hot", 0)
+	// Saturate the sketch's counters for hot, so that no cold key can be
+	// estimated more popular whatever it collides with
+	for range sketchMaxCount - 1 {
+		c.Get("hot")
+	}
+	for i := range 3 {
+		c.Set("cold"+strconv.Itoa(i), i)
+	}
+	checkResident(t, c, map[string]bool{"hot": true, "cold0": false, "cold1": false, "cold2": true})
+}
+
+func TestClockSecondChance(t *testing.T) {
+	c := NewLRUCache[string, int](3, 0, WithPolicy(NewClockPolicy[string]))
+	c.Set("a", 0)
+	c.Set("b", 1)
+	c.Set("c", 2)
+	// The hand starts at a, finds its reference bit set, clears it and
+	// moves on to evict b instead
+	c.Get("a")
+	c.Set("d", 3)
+	checkResident(t, c, map[string]bool{"a": true, "b": false, "c": true, "d": true})
+}
+
+func TestFIFOIgnoresReads(t *testing.T) {
+	c := NewLRUCache[string, int](2, 0, WithPolicy(NewFIFOPolicy[string]))
+	c.Set("a", 0)
+	c.Set("b", 1)
+	c.Get("a")
+	c.Set("a", 2)
+	c.Set("c", 3)
+	checkResident(t, c, map[string]bool{"a": false, "b": true, "c": true})
+}
+
+func TestRandomEvictsVariousKeys(t *testing.T) {
+	evicted := map[string]bool{}
+	for range 20 {
+		c := NewLRUCache[string, int](10, 0, WithPolicy(NewRandomPolicy[string]))
+		for i := range 11 {
+			c.Set(strconv.Itoa(i), i)
+		}
+		if c.Len() != 10 {
+			t.Fatalf("Len() = %d, want 10", c.Len())
+		}
+		for i := range 11 {
+			if key := strconv.Itoa(i); !c.Contains(key) {
+				evicted[key] = true
+			}
+		}
+	}
+	if len(evicted) < 2 {
+		t.Errorf("20 evictions all chose the same key: %v", evicted)
+	}
+}
+
+func TestLFUEvictsLeastFrequent(t *testing.T) {
+	c := NewLRUCache[string, int](3, 0, WithPolicy(NewLFUPolicy[string]))
+	c.Set("a", 0)
+	c.Set("c", 2)
+	c.Get("a")
+	c.Get("c")
+	c.Set("b", 1)
+	// a is the least recently used key, but b and d were used least often,
+	// and of those b the longest ago
+	c.Set("d", 3)
+	checkResident(t, c, map[string]bool{"a": true, "b": false, "c": true, "d": true})
+}
+
+func TestCompareAndSwapRejectedWrite(t *testing.T) {
+	c := NewLRUCache[string, int](10, 0, WithAdmit(func(key string, value int) bool { return value >= 0 }))
+	version, ok := c.CompareAndSwap("k", 0, 1)
+	if !ok {
+		t.Fatal("CompareAndSwap of a missing key failed")
+	}
+	if version, ok := c.CompareAndSwap("k", version, -1); ok || version != 0 {
+		t.Errorf("CompareAndSwap of a vetoed value = %d, %v, want 0, false", version, ok)
+	}
+	if c.Contains("k") {
+		t.Error("old value kept after a vetoed CompareAndSwap")
+	}
+}
+
+func TestIncrRejectedWrite(t *testing.T) {
+	c := NewLRUCache[string, int](10, 0, WithDoorkeeper())
+	if _, ok := Incr(c, "n", 1); ok {
+		t.Error("Incr reported a write the doorkeeper turned away as kept")
+	}
+	if got, ok := Incr(c, "n", 1); !ok || got != 1 {
+		t.Errorf("second Incr = %d, %v, want 1, true", got, ok)
+	}
+	if got, ok := Decr(c, "n", 3); !ok || got != -2 {
+		t.Errorf("Decr = %d, %v, want -2, true", got, ok)
+	}
+}
+
+func TestClosedCacheReportsFailure(t *testing.T) {
+	c := NewLRUCache[string, int](10, 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	loaded := make(chan error)
+	go func() {
+		_, err := c.GetOrLoad("slow", func(string) (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+		loaded <- err
+	}()
+	<-started
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-loaded; !errors.Is(err, ErrClosed) {
+		t.Errorf("GetOrLoad under way at Close = %v, want ErrClosed", err)
+	}
+
+	if _, err := c.GetOrLoad("k", func(string) (int, error) { return 1, nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("GetOrLoad = %v, want ErrClosed", err)
+	}
+	if err := c.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Close = %v, want ErrClosed", err)
+	}
+	if c.SetIfAbsent("k", 1, NoExpiration) {
+		t.Error("SetIfAbsent on a closed cache reported the value stored")
+	}
+	if _, ok := c.CompareAndSwap("k", 0, 1); ok {
+		t.Error("CompareAndSwap on a closed cache succeeded")
+	}
+	if _, ok := Incr(c, "k", 1); ok {
+		t.Error("Incr on a closed cache reported the value kept")
+	}
+	if c.Len() != 0 {
+		t.Errorf("closed cache holds %d entries", c.Len())
+	}
+}
+
+func TestSetWithOptionsReportsReplaced(t *testing.T) {
+	c := NewLRUCache[string, int](10, 0)
+	tests := []struct {
+		opts             SetOptions
+		stored, replaced bool
+	}{
+		{SetOptions{IfPresent: true}, false, false},
+		{SetOptions{}, true, false},
+		{SetOptions{}, true, true},
+		{SetOptions{IfAbsent: true}, false, true},
+		{SetOptions{Negative: true}, true, true},
+		// A negative entry holds no value to replace
+		{SetOptions{}, true, false},
+	}
+	for i, tt := range tests {
+		if stored, replaced := c.SetWithOptions("k", i, tt.opts); stored != tt.stored || replaced != tt.replaced {
+			t.Errorf("write %d with %+v = %v, %v, want %v, %v", i, tt.opts, stored, replaced, tt.stored, tt.replaced)
+		}
+	}
+}
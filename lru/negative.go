@@ -0,0 +1,51 @@
+package lru
+
+import (
+	"errors"
+	"time"
+)
+
+// LookupResult describes the outcome of a Lookup.
+type LookupResult int
+
+const (
+	// Miss means the key is not in the cache.
+	Miss LookupResult = iota
+	// Hit means the key is cached with a value.
+	Hit
+	// NegativeHit means the key is cached as known to be missing from the
+	// backing source.
+	NegativeHit
+)
+
+// ErrNegativeEntry is returned by GetOrLoad when the key is cached as
+// known to be missing, so the loader is not called.
+var ErrNegativeEntry = errors.New("lru: key is cached as missing")
+
+// SetNegative caches the key as known to be missing for the given ttl.
+// Negative entries take up capacity like any other entry and are replaced
+// by the next write to the key. Get reports them as misses; use Lookup to
+// tell them apart.
+func (lru *LRUCache[K, V]) SetNegative(key K, ttl time.Duration) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	var zero V
+	if item := lru.setTTL(key, zero, ttl); item != nil {
+		item.negative = true
+	}
+}
+
+// Lookup behaves like Get but distinguishes a plain miss from a negative
+// entry stored with SetNegative. Like Get, hits on live entries only take
+// the read lock.
+func (lru *LRUCache[K, V]) Lookup(key K) (V, LookupResult) {
+	if v, result, ok := lru.readLookup(key); ok {
+		return v, result
+	}
+
+	lru.lock()
+	defer lru.unlock()
+
+	return lru.lookup(key)
+}
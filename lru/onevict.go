@@ -0,0 +1,82 @@
+package lru
+
+// EvictionReason tells an OnEvict callback why an entry left the cache.
+type EvictionReason int
+
+const (
+	// ReasonCapacity means the entry was evicted to make room.
+	ReasonCapacity EvictionReason = iota
+	// ReasonExpired means the entry outlived its TTL.
+	ReasonExpired
+	// ReasonDeleted means the entry was removed explicitly, by Delete,
+	// GetDel, RemoveOldest or Clear.
+	ReasonDeleted
+	// ReasonReplaced means the entry's value was overwritten.
+	ReasonReplaced
+)
+
+// String returns the lower-case name of the reason.
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// eviction is an OnEvict or OnExpire call waiting for the cache lock to
+// be released.
+type eviction[K comparable, V any] struct {
+	entry  Entry[K, V]
+	reason EvictionReason
+}
+
+// WithOnEvict registers onEvict to be called with the key and value of
+// every entry that leaves the cache, or whose value is overwritten, along
+// with the reason. onEvict is called after the operation that removed the
+// entry, without the cache lock held, so it may use the cache. The key and
+// value types must match the cache's, or NewLRUCache panics.
+func WithOnEvict[K comparable, V any](onEvict func(key K, value V, reason EvictionReason)) Option {
+	return func(o *options) {
+		o.onEvict = onEvict
+	}
+}
+
+// notify queues the OnEvict and OnExpire calls for item's current value.
+// The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) notify(item *CacheItem[K, V], reason EvictionReason) {
+	expiry := reason == ReasonExpired && (lru.onExpire != nil || lru.expiredCh != nil)
+	if lru.onEvict != nil || expiry {
+		entry := Entry[K, V]{item.key, item.value, item.expireAt}
+		lru.evictions = append(lru.evictions, eviction[K, V]{entry, reason})
+	}
+}
+
+// unlock releases lru.mu and then makes the OnEvict and OnExpire calls
+// queued while it was held.
+func (lru *LRUCache[K, V]) unlock() {
+	pending := lru.evictions
+	lru.evictions = nil
+	lru.mu.Unlock()
+
+	for _, e := range pending {
+		if lru.onEvict != nil {
+			lru.onEvict(e.entry.Key, e.entry.Value, e.reason)
+		}
+		if e.reason != ReasonExpired {
+			continue
+		}
+		if lru.onExpire != nil {
+			lru.onExpire(e.entry.Key, e.entry.Value)
+		}
+		if lru.expiredCh != nil {
+			lru.sendExpired(e.entry)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package lru
+
+import "fmt"
+
+// WithOnExpire registers onExpire to be called with the key and value of
+// every entry whose TTL lapses, so that applications can refresh or
+// persist the data. Expiry is noticed when the entry is next accessed or
+// overwritten, or when the cleanup goroutine sweeps the cache. onExpire is
+// called like an OnEvict callback, without the cache lock held. The key
+// and value types must match the cache's, or NewLRUCache panics.
+func WithOnExpire[K comparable, V any](onExpire func(key K, value V)) Option {
+	return func(o *options) {
+		o.onExpire = onExpire
+	}
+}
+
+// WithExpiredChannel makes Expired return a channel that receives every
+// expired entry, buffered to hold buffer entries. Entries that expire
+// while the buffer is full are dropped rather than blocking the cache.
+// It panics if buffer < 1.
+func WithExpiredChannel(buffer int) Option {
+	if buffer < 1 {
+		panic(fmt.Sprintf("lru: expired channel buffer %d less than 1", buffer))
+	}
+	return func(o *options) {
+		o.expiredBuffer = buffer
+	}
+}
+
+// Expired returns the channel of expired entries enabled by
+// WithExpiredChannel, or nil if the cache was created without it.
+func (lru *LRUCache[K, V]) Expired() <-chan Entry[K, V] {
+	return lru.expiredCh
+}
+
+// sendExpired offers an expired entry to the Expired channel without
+// blocking.
+func (lru *LRUCache[K, V]) sendExpired(e Entry[K, V]) {
+	select {
+	case lru.expiredCh <- e:
+	default:
+	}
+}
@@ -0,0 +1,224 @@
+package lru
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option configures a cache created by NewLRUCache.
+type Option func(*options)
+
+// options holds the settings collected from Options. Settings that depend
+// on the cache's type parameters are stored as any and checked by
+// NewLRUCache.
+type options struct {
+	newPolicy     any // func(capacity int) EvictionPolicy[K]
+	weigher       any // func(key K, value V) int64
+	maxBytes      int64
+	pressure      *memoryPressure
+	batch         int     // entries to evict at once
+	batchFrac     float64 // share of the capacity to evict at once
+	onEvict       any     // func(key K, value V, reason EvictionReason)
+	onExpire      any     // func(key K, value V)
+	expiredBuffer int
+	admit         any // func(key K, value V) bool
+	doorkeeper    bool
+	noCleanup     bool
+	noHitRates    bool
+	interval      time.Duration
+	sliding       bool
+	jitter        float64
+	maxIdle       time.Duration
+	grace         time.Duration
+	beta          float64
+	ahead         time.Duration
+	writeBuffer   int
+	hotKeys       int
+	shardHash     any // func(key K) uint64
+}
+
+// WithPolicy makes the cache use the eviction policy built by newPolicy,
+// such as NewLRUPolicy[string]. newPolicy is called once per priority tier
+// with the cache capacity. The key type must match the cache's, or
+// NewLRUCache panics.
+func WithPolicy[K comparable](newPolicy func(capacity int) EvictionPolicy[K]) Option {
+	return func(o *options) {
+		o.newPolicy = newPolicy
+	}
+}
+
+// WithWeigher makes the capacity of the cache a total weight budget
+// rather than an entry count. weigher returns the weight of an entry, which
+// must not be negative, and is called whenever the entry is written.
+// Entries are evicted until the total weight fits the capacity; an entry
+// heavier than the whole capacity is not stored. The key and value types
+// must match the cache's, or NewLRUCache panics.
+func WithWeigher[K comparable, V any](weigher func(key K, value V) int64) Option {
+	return func(o *options) {
+		o.weigher = weigher
+	}
+}
+
+// WithMaxBytes bounds the estimated memory held by the cache's entries, in
+// addition to its capacity. The size of an entry is estimated from the
+// length of its key and value plus a fixed overhead. Entries are evicted
+// until the cache is under budget; an entry larger than the whole budget is
+// not stored.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(o *options) {
+		o.maxBytes = maxBytes
+	}
+}
+
+// WithEvictionBatch makes the cache evict at least n entries whenever a
+// write overflows it, rather than just enough to fit, so that sustained
+// inserts take the eviction path less often. It panics if n < 1.
+func WithEvictionBatch(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("lru: eviction batch %d less than 1", n))
+	}
+	return func(o *options) {
+		o.batch = n
+	}
+}
+
+// WithEvictionBatchFraction is like WithEvictionBatch but sizes the batch
+// as a share of the capacity. It panics unless 0 < fraction <= 1.
+func WithEvictionBatchFraction(fraction float64) Option {
+	if fraction <= 0 || fraction > 1 {
+		panic(fmt.Sprintf("lru: eviction batch fraction %v not in (0, 1]", fraction))
+	}
+	return func(o *options) {
+		o.batchFrac = fraction
+	}
+}
+
+// WithAdmit registers admit to vet every write before it is stored, so
+// that applications can keep values that are too large, sensitive or
+// otherwise non-cacheable out of the cache without displacing existing
+// entries. A write that admit rejects is dropped, and any old value under
+// the key is removed as replaced. admit is called with the cache lock
+// held and must not use the cache. The key and value types must match the
+// cache's, or NewLRUCache panics.
+func WithAdmit[K comparable, V any](admit func(key K, value V) bool) Option {
+	return func(o *options) {
+		o.admit = admit
+	}
+}
+
+// WithDoorkeeper puts a bloom filter in front of the cache that only
+// stores a new key on the second write of it, so that keys accessed once
+// do not push out entries that are used repeatedly. The filter remembers
+// about as many keys as the cache capacity before it starts over.
+func WithDoorkeeper() Option {
+	return func(o *options) {
+		o.doorkeeper = true
+	}
+}
+
+// WithoutCleanup disables the background goroutine that periodically
+// removes expired entries. Expired entries are then only removed when they
+// are accessed or chosen for eviction, and until then count towards the
+// capacity.
+func WithoutCleanup() Option {
+	return func(o *options) {
+		o.noCleanup = true
+	}
+}
+
+// WithoutHitRates disables the rolling window behind HitRates, which then
+// reports zero rates. Lookups of entries without an expiration then do not
+// read the clock.
+func WithoutHitRates() Option {
+	return func(o *options) {
+		o.noHitRates = true
+	}
+}
+
+// WithCleanupInterval sets how often the cleanup goroutine removes expired
+// entries, independently of the default TTL. The interval is one minute
+// unless set. It panics if interval is not positive.
+func WithCleanupInterval(interval time.Duration) Option {
+	if interval <= 0 {
+		panic("lru: non-positive cleanup interval")
+	}
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithSlidingExpiration makes every successful read of an entry renew its
+// TTL, so that entries live for as long as they keep being used, as
+// sessions do. Peek, TTL and Contains do not renew it.
+func WithSlidingExpiration() Option {
+	return func(o *options) {
+		o.sliding = true
+	}
+}
+
+// WithTTLJitter varies the TTL of every written entry by a random amount
+// of up to fraction of it either way, so that entries written together do
+// not all expire at once and send a thundering herd of refreshes to the
+// backing store. It panics unless 0 <= fraction < 1.
+func WithTTLJitter(fraction float64) Option {
+	if fraction < 0 || fraction >= 1 {
+		panic(fmt.Sprintf("lru: TTL jitter %v not in [0, 1)", fraction))
+	}
+	return func(o *options) {
+		o.jitter = fraction
+	}
+}
+
+// WithMaxIdle expires entries that have not been read or written for
+// maxIdle, even if their TTL has not lapsed, like Caffeine's
+// expireAfterAccess. Entries that never expire are subject to it too.
+// It panics if maxIdle is not positive.
+func WithMaxIdle(maxIdle time.Duration) Option {
+	if maxIdle <= 0 {
+		panic("lru: non-positive max idle")
+	}
+	return func(o *options) {
+		o.maxIdle = maxIdle
+	}
+}
+
+// WithStaleWhileRevalidate keeps expired entries for a further grace
+// period in which GetOrLoad and GetOrLoadStale return them straight away
+// while refreshing them with the loader in the background, hiding the
+// loader's latency. Other reads treat such entries as missing. It panics
+// if grace is not positive.
+func WithStaleWhileRevalidate(grace time.Duration) Option {
+	if grace <= 0 {
+		panic("lru: non-positive stale grace period")
+	}
+	return func(o *options) {
+		o.grace = grace
+	}
+}
+
+// WithEarlyRefresh makes GetOrLoad reload values probabilistically before
+// they expire, using the XFetch algorithm, so that hot keys do not all
+// miss at once and stampede the loader. The chance of an early reload
+// grows as expiry nears and with the time the loader took; beta scales
+// it, with 1 a good default and larger values refreshing earlier. It
+// panics if beta is not positive.
+func WithEarlyRefresh(beta float64) Option {
+	if beta <= 0 {
+		panic(fmt.Sprintf("lru: early refresh beta %v not positive", beta))
+	}
+	return func(o *options) {
+		o.beta = beta
+	}
+}
+
+// WithRefreshAhead makes a GetOrLoad hit on an entry due to expire within
+// window reload it with the loader in the background, so that keys which
+// stay hot never observe a miss. It panics if window is not positive.
+func WithRefreshAhead(window time.Duration) Option {
+	if window <= 0 {
+		panic("lru: non-positive refresh-ahead window")
+	}
+	return func(o *options) {
+		o.ahead = window
+	}
+}
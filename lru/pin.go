@@ -0,0 +1,41 @@
+package lru
+
+import "time"
+
+// Pin exempts the key from capacity eviction until it is unpinned. Pinned
+// entries still expire and can still be deleted. If every entry is pinned,
+// the cache grows beyond its capacity rather than evicting one. Pin
+// reports whether the key was present.
+func (lru *LRUCache[K, V]) Pin(key K) bool {
+	return lru.setPinned(key, true)
+}
+
+// Unpin makes the key subject to capacity eviction again. It reports
+// whether the key was present.
+func (lru *LRUCache[K, V]) Unpin(key K) bool {
+	return lru.setPinned(key, false)
+}
+
+// setPinned updates the pinned flag of the key. Pinned items are taken out
+// of their eviction policy so it can never pick them as a victim.
+func (lru *LRUCache[K, V]) setPinned(key K, pinned bool) bool {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	item, found := lru.cache[key]
+	if !found || item.expired(time.Now()) {
+		return false
+	}
+	if item.pinned == pinned {
+		return true
+	}
+	item.pinned = pinned
+	if pinned {
+		lru.policies[item.priority].OnRemove(key)
+		lru.pinned[key] = item
+	} else {
+		delete(lru.pinned, key)
+		lru.policies[item.priority].OnSet(key)
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package lru
+
+// EvictionPolicy decides which entry the cache evicts when it is full.
+// The cache keeps one policy instance per priority tier and serializes all
+// calls to it, so implementations need no locking of their own.
+type EvictionPolicy[K comparable] interface {
+	// OnGet records a read of a key the policy is tracking.
+	OnGet(key K)
+	// OnSet records the insertion of a new key or the update of a tracked
+	// one.
+	OnSet(key K)
+	// OnRemove forgets a tracked key that has left the cache.
+	OnRemove(key K)
+	// Victim returns the tracked key to evict next, or false if the policy
+	// is tracking no keys. The cache calls OnRemove once it has removed the
+	// victim.
+	Victim() (K, bool)
+}
+
+// walker is implemented by policies that can list their keys from the
+// most to the least worth keeping. Keys, Entries and Range use it to order
+// the keys of each priority tier.
+type walker[K comparable] interface {
+	walk(f func(key K))
+}
+
+// resizer is implemented by policies whose behavior depends on the cache
+// capacity. Resize passes them the new capacity.
+type resizer interface {
+	resize(capacity int)
+}
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy[K comparable] struct {
+	list  *linkedList[K] // keys, most recently used at the front
+	elems map[K]*node[K]
+}
+
+// NewLRUPolicy returns a least recently used eviction policy, the default
+// for NewLRUCache.
+func NewLRUPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &lruPolicy[K]{
+		list:  newLinkedList[K](),
+		elems: make(map[K]*node[K]),
+	}
+}
+
+func (p *lruPolicy[K]) OnGet(key K) {
+	if elem, found := p.elems[key]; found {
+		p.list.MoveToFront(elem)
+	}
+}
+
+func (p *lruPolicy[K]) OnSet(key K) {
+	if elem, found := p.elems[key]; found {
+		p.list.MoveToFront(elem)
+		return
+	}
+	p.elems[key] = p.list.PushFront(key)
+}
+
+func (p *lruPolicy[K]) OnRemove(key K) {
+	if elem, found := p.elems[key]; found {
+		p.list.Remove(elem)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy[K]) Victim() (K, bool) {
+	if elem := p.list.Back(); elem != nil {
+		return elem.Value, true
+	}
+	var zero K
+	return zero, false
+}
+
+func (p *lruPolicy[K]) walk(f func(key K)) {
+	for elem := p.list.Front(); elem != nil; elem = elem.Next() {
+		f(elem.Value)
+	}
+}
@@ -0,0 +1,18 @@
+package lru
+
+// newItem returns a zeroed item, reusing one removed from the cache if the
+// pool holds any.
+func (lru *LRUCache[K, V]) newItem() *CacheItem[K, V] {
+	if item, ok := lru.items.Get().(*CacheItem[K, V]); ok {
+		return item
+	}
+	return new(CacheItem[K, V])
+}
+
+// recycle zeroes an item that has left the cache, dropping its references
+// to the key and value, and returns it to the pool for newItem. The caller
+// must hold lru.mu and must not use item afterwards.
+func (lru *LRUCache[K, V]) recycle(item *CacheItem[K, V]) {
+	*item = CacheItem[K, V]{}
+	lru.items.Put(item)
+}
@@ -0,0 +1,83 @@
+package lru
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// memoryCheckInterval is how often a cache created with WithMemoryPressure
+// samples heap usage.
+const memoryCheckInterval = time.Second
+
+// heapMetric is the runtime metric compared against the heap limit.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// defaultLimitShare is the share of the runtime memory limit used as the
+// heap limit when WithMemoryPressure is given none.
+const defaultLimitShare = 0.9
+
+// memoryPressure holds the settings of WithMemoryPressure.
+type memoryPressure struct {
+	heapLimit     uint64
+	evictFraction float64
+}
+
+// WithMemoryPressure makes the cache watch heap usage and, whenever the
+// bytes held by heap objects exceed heapLimit, evict evictFraction of its
+// entries, choosing them as Set would. A heapLimit of 0 means 90% of the
+// runtime memory limit set by GOMEMLIMIT or debug.SetMemoryLimit; with no
+// such limit the option has no effect. Heap usage is sampled every second.
+// WithMemoryPressure panics unless 0 < evictFraction <= 1.
+func WithMemoryPressure(heapLimit uint64, evictFraction float64) Option {
+	if evictFraction <= 0 || evictFraction > 1 {
+		panic(fmt.Sprintf("lru: memory pressure evict fraction %v not in (0, 1]", evictFraction))
+	}
+	return func(o *options) {
+		o.pressure = &memoryPressure{heapLimit, evictFraction}
+	}
+}
+
+// limit returns the heap limit in bytes, or 0 if there is none.
+func (mp *memoryPressure) limit() uint64 {
+	if mp.heapLimit > 0 {
+		return mp.heapLimit
+	}
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return 0
+	}
+	return uint64(float64(limit) * defaultLimitShare)
+}
+
+// watchMemory periodically compares heap usage with the limit of mp and
+// sheds entries while it is exceeded, until the cache is closed.
+func (lru *LRUCache[K, V]) watchMemory(mp *memoryPressure) {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	for lru.sleep(memoryCheckInterval) {
+		limit := mp.limit()
+		if limit == 0 {
+			continue
+		}
+		metrics.Read(sample)
+		if sample[0].Value.Kind() == metrics.KindUint64 && sample[0].Value.Uint64() > limit {
+			lru.shed(mp.evictFraction)
+		}
+	}
+}
+
+// shed evicts the given fraction of the cache's entries, rounded up, and
+// returns the number evicted.
+func (lru *LRUCache[K, V]) shed(fraction float64) int {
+	lru.lock()
+	defer lru.unlock()
+
+	n := int(math.Ceil(float64(len(lru.cache)) * fraction))
+	evicted := 0
+	for evicted < n && lru.evict() {
+		evicted++
+	}
+	return evicted
+}
@@ -0,0 +1,137 @@
+package lru
+
+import "time"
+
+// Priority is the eviction tier of an entry. When the cache is full,
+// entries of a lower priority are evicted before any of a higher one.
+// Values below PriorityLow are treated as PriorityLow and values above
+// PriorityHigh as PriorityHigh.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// numPriorities is the number of priority tiers.
+const numPriorities = int(PriorityHigh) + 1
+
+// keepPriority tells store to leave an existing entry in its tier and put
+// a new one in PriorityNormal.
+const keepPriority Priority = -1
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// clamp returns the tier p is treated as.
+func (p Priority) clamp() Priority {
+	return min(max(p, PriorityLow), PriorityHigh)
+}
+
+// SetWithPriority behaves like Set but also places the entry in the given
+// priority tier. Entries written with Set start out as PriorityNormal and
+// keep their priority when updated. The entry is in its tier before room
+// is made for it, so a low-priority write to a full cache evicts the
+// oldest low-priority entry, possibly itself, rather than a normal one.
+func (lru *LRUCache[K, V]) SetWithPriority(key K, value V, priority Priority) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	ttl := lru.jitter(lru.ttl)
+	lru.store(key, value, expireAfter(ttl), ttl, priority.clamp())
+}
+
+// SetOptions describes a write made with SetWithOptions.
+type SetOptions struct {
+	// TTL is the entry's lifetime, as in SetWithTTL; zero means the
+	// cache's default expiration.
+	TTL time.Duration
+	// Deadline, if not zero, expires the entry at that time, as in
+	// SetWithDeadline, instead of after TTL.
+	Deadline time.Time
+	// Priority, if not nil, places the entry in that tier, as in
+	// SetWithPriority.
+	Priority *Priority
+	// Negative caches the key as known to be missing, as SetNegative
+	// does; the value is ignored.
+	Negative bool
+	// IfAbsent and IfPresent make the write conditional, as in
+	// SetIfAbsent and SetIfPresent.
+	IfAbsent, IfPresent bool
+}
+
+// SetWithOptions performs the write described by opts under a single
+// lock, so that no other operation sees it half applied. It reports
+// whether the value was stored, which is false only when IfAbsent or
+// IfPresent prevented it, and whether it replaced a live value, as
+// Contains would have reported just before.
+func (lru *LRUCache[K, V]) SetWithOptions(key K, value V, opts SetOptions) (stored, replaced bool) {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	replaced = lru.live(key)
+	if opts.IfAbsent && replaced || opts.IfPresent && !replaced {
+		return false, replaced
+	}
+	priority := keepPriority
+	if opts.Priority != nil {
+		priority = opts.Priority.clamp()
+	}
+	if opts.Negative {
+		var zero V
+		value = zero
+	}
+	var item *CacheItem[K, V]
+	if !opts.Deadline.IsZero() {
+		item = lru.store(key, value, opts.Deadline, time.Until(opts.Deadline), priority)
+	} else {
+		ttl := opts.TTL
+		if ttl == 0 {
+			ttl = lru.ttl
+		}
+		ttl = lru.jitter(ttl)
+		item = lru.store(key, value, expireAfter(ttl), ttl, priority)
+	}
+	if item != nil {
+		item.negative = opts.Negative
+	}
+	return true, replaced
+}
+
+// SetPriority moves the key to the given priority tier. It reports whether
+// the key was present.
+func (lru *LRUCache[K, V]) SetPriority(key K, priority Priority) bool {
+	lru.lockOrdered()
+	defer lru.unlock()
+
+	item, found := lru.cache[key]
+	if !found || item.expired(time.Now()) {
+		return false
+	}
+	lru.setPriority(item, priority.clamp())
+	return true
+}
+
+// setPriority moves item to the eviction policy of the given priority.
+// The caller must hold lru.mu.
+func (lru *LRUCache[K, V]) setPriority(item *CacheItem[K, V], priority Priority) {
+	if item.priority == priority {
+		return
+	}
+	if !item.pinned {
+		lru.policies[item.priority].OnRemove(item.key)
+		lru.policies[priority].OnSet(item.key)
+	}
+	item.priority = priority
+}
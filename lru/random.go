@@ -0,0 +1,47 @@
+package lru
+
+import "math/rand/v2"
+
+// randomPolicy evicts a key chosen uniformly at random.
+type randomPolicy[K comparable] struct {
+	keys  []K
+	index map[K]int // position of each key in keys
+}
+
+// NewRandomPolicy returns an eviction policy that evicts random keys. It
+// keeps no access history, so reads cost nothing.
+func NewRandomPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return &randomPolicy[K]{
+		keys:  make([]K, 0, capacity),
+		index: make(map[K]int),
+	}
+}
+
+func (p *randomPolicy[K]) OnGet(key K) {}
+
+func (p *randomPolicy[K]) OnSet(key K) {
+	if _, found := p.index[key]; !found {
+		p.index[key] = len(p.keys)
+		p.keys = append(p.keys, key)
+	}
+}
+
+func (p *randomPolicy[K]) OnRemove(key K) {
+	i, found := p.index[key]
+	if !found {
+		return
+	}
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.index[p.keys[i]] = i
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
+
+func (p *randomPolicy[K]) Victim() (K, bool) {
+	if len(p.keys) == 0 {
+		var zero K
+		return zero, false
+	}
+	return p.keys[rand.IntN(len(p.keys))], true
+}
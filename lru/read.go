@@ -0,0 +1,136 @@
+package lru
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ringSize is the number of reads a readRing buffers. It must be a power
+// of two.
+const ringSize = 16
+
+// readRing is a lossy, bounded buffer of the items hit by reads made under
+// the read lock, waiting to be promoted in the eviction policy. Any number
+// of readers may offer to it; only the holder of the write lock drains it.
+type readRing[K comparable, V any] struct {
+	head  atomic.Uint64 // next slot to drain
+	tail  atomic.Uint64 // next slot to fill
+	slots [ringSize]atomic.Pointer[CacheItem[K, V]]
+}
+
+// offer buffers item, dropping it if the ring is full or another reader
+// claimed the same slot. It reports whether the ring is full.
+func (r *readRing[K, V]) offer(item *CacheItem[K, V]) bool {
+	head, tail := r.head.Load(), r.tail.Load()
+	if tail-head >= ringSize {
+		return true
+	}
+	if r.tail.CompareAndSwap(tail, tail+1) {
+		r.slots[tail&(ringSize-1)].Store(item)
+	}
+	return tail+1-head >= ringSize
+}
+
+// drain calls f for each buffered item, stopping early at a slot whose
+// reader has claimed it but not yet stored the item. The caller must hold
+// the cache's write lock.
+func (r *readRing[K, V]) drain(f func(item *CacheItem[K, V])) {
+	head, tail := r.head.Load(), r.tail.Load()
+	for ; head != tail; head++ {
+		item := r.slots[head&(ringSize-1)].Swap(nil)
+		if item == nil {
+			break
+		}
+		f(item)
+	}
+	r.head.Store(head)
+}
+
+// newReadRings returns the read buffers for a cache: one per processor,
+// rounded up to a power of two, so that concurrent readers rarely contend
+// on the same ring.
+func newReadRings[K comparable, V any]() []readRing[K, V] {
+	n := 1 << bits.Len(uint(runtime.GOMAXPROCS(0)-1))
+	return make([]readRing[K, V], n)
+}
+
+// lock acquires lru.mu for writing and applies the promotions buffered by
+// reads since it was last held. The cleanup goroutine takes the lock on
+// every cycle, so the buffers are also drained periodically.
+func (lru *LRUCache[K, V]) lock() {
+	lru.mu.Lock()
+	lru.drainReads()
+}
+
+// drainReads applies the buffered promotions to the eviction policies and
+// counts the buffered reads towards HotKeys. Items that have since been
+// removed or replaced are skipped, and pinned ones are not promoted. The
+// caller must hold lru.mu.
+func (lru *LRUCache[K, V]) drainReads() {
+	for i := range lru.reads {
+		lru.reads[i].drain(func(item *CacheItem[K, V]) {
+			if lru.cache[item.key] != item {
+				return
+			}
+			if !item.pinned {
+				lru.policies[item.priority].OnGet(item.key)
+			}
+			if lru.hot != nil {
+				lru.hot.record(item.key)
+			}
+		})
+	}
+}
+
+// stripe picks the read ring for a reader. With a single ring it skips
+// the random number generator.
+func (lru *LRUCache[K, V]) stripe() int {
+	if len(lru.reads) == 1 {
+		return 0
+	}
+	return int(rand.Uint32() & uint32(len(lru.reads)-1))
+}
+
+// readLookup is lookup under the read lock, so that concurrent reads do not
+// serialize. The promotion of a hit is buffered in a randomly chosen read
+// ring rather than applied, and dropped if that ring is full; a reader
+// that fills a ring drains the buffers if the write lock is free. The
+// final result is false if the lookup needs the write lock: the entry has
+// expired and may have to be removed, or reads renew expirations.
+func (lru *LRUCache[K, V]) readLookup(key K) (V, LookupResult, bool) {
+	var zero V
+	lru.mu.RLock()
+	item, found := lru.cache[key]
+	// The clock is only read when the entry can expire or the hit-rate
+	// window needs it, so hits on entries without a TTL are cheaper
+	var now time.Time
+	if !lru.noWindow || found && !item.expireAt.IsZero() {
+		now = time.Now()
+	}
+	switch {
+	case !found:
+		lru.mu.RUnlock()
+		lru.recordMiss(now)
+		return zero, Miss, true
+	case item.expired(now) || lru.sliding || lru.maxIdle > 0:
+		lru.mu.RUnlock()
+		return zero, Miss, false
+	}
+	item.accesses.Add(1)
+	value, negative := item.value, item.negative
+	full := lru.reads[lru.stripe()].offer(item)
+	lru.mu.RUnlock()
+
+	lru.recordHit(now)
+	if full && lru.mu.TryLock() {
+		lru.drainReads()
+		lru.unlock()
+	}
+	if negative {
+		return zero, NegativeHit, true
+	}
+	return value, Hit, true
+}
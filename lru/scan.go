@@ -0,0 +1,118 @@
+package lru
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultScanCount is the page size used by Scan when count is not
+// positive.
+const defaultScanCount = 10
+
+// Scan returns up to count non-expired keys starting after cursor, along
+// with the cursor to pass to the next call. Start with a cursor of 0; a
+// returned cursor of 0 means the iteration is complete.
+//
+// Keys are visited in insertion order, so the lock is only held for one
+// page at a time. Every key present for the whole iteration is returned
+// exactly once; keys added or removed during the iteration may or may not
+// be returned.
+func (lru *LRUCache[K, V]) Scan(cursor uint64, count int) ([]K, uint64) {
+	keys := []K{}
+	next := lru.scan(cursor, count, func(item *CacheItem[K, V]) {
+		keys = append(keys, item.key)
+	})
+	return keys, next
+}
+
+// ScanEntries behaves like Scan but returns the entries rather than just
+// their keys. Negative entries are skipped, so a page may hold fewer than
+// count entries even when the iteration is not complete.
+func (lru *LRUCache[K, V]) ScanEntries(cursor uint64, count int) ([]Entry[K, V], uint64) {
+	entries := []Entry[K, V]{}
+	next := lru.scan(cursor, count, func(item *CacheItem[K, V]) {
+		if !item.negative {
+			entries = append(entries, Entry[K, V]{item.key, item.value, item.expireAt})
+		}
+	})
+	return entries, next
+}
+
+// scan calls f for up to count non-expired items starting after cursor and
+// returns the cursor to continue from, as described for Scan.
+func (lru *LRUCache[K, V]) scan(cursor uint64, count int, f func(item *CacheItem[K, V])) uint64 {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	slots := lru.order.slots
+	i := lru.order.after(cursor)
+	now := time.Now()
+	for n := 0; i < len(slots) && n < count; i++ {
+		item := slots[i].item
+		cursor = slots[i].seq
+		if item != nil && !item.expired(now) {
+			f(item)
+			n++
+		}
+	}
+	if i == len(slots) {
+		return 0
+	}
+	return cursor
+}
+
+// scanSlot is a position in the insertion order. It keeps the seq of its
+// item once the item is removed, so that a cursor naming a removed item
+// can still be resumed from.
+type scanSlot[K comparable, V any] struct {
+	seq  uint64
+	item *CacheItem[K, V] // nil once removed
+}
+
+// scanOrder holds the items in insertion order, which is also the order of
+// their seqs, so that Scan finds where a cursor resumes by binary search.
+// Removed items leave holes, which are compacted away once they make up
+// half the slots.
+type scanOrder[K comparable, V any] struct {
+	slots []scanSlot[K, V]
+	holes int
+}
+
+// push appends item, which must have the highest seq yet.
+func (o *scanOrder[K, V]) push(item *CacheItem[K, V]) {
+	item.scanIndex = len(o.slots)
+	o.slots = append(o.slots, scanSlot[K, V]{item.seq, item})
+}
+
+// remove removes item.
+func (o *scanOrder[K, V]) remove(item *CacheItem[K, V]) {
+	o.slots[item.scanIndex].item = nil
+	o.holes++
+	if o.holes > len(o.slots)/2 {
+		o.compact()
+	}
+}
+
+// compact drops the holes left by removed items.
+func (o *scanOrder[K, V]) compact() {
+	live := o.slots[:0]
+	for _, slot := range o.slots {
+		if slot.item != nil {
+			slot.item.scanIndex = len(live)
+			live = append(live, slot)
+		}
+	}
+	clear(o.slots[len(live):])
+	o.slots = live
+	o.holes = 0
+}
+
+// after returns the index of the first slot whose seq is greater than
+// cursor.
+func (o *scanOrder[K, V]) after(cursor uint64) int {
+	return sort.Search(len(o.slots), func(i int) bool { return o.slots[i].seq > cursor })
+}
@@ -0,0 +1,544 @@
+package lru
+
+import (
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxShards is the most shards a ShardedCache may have. Scan cursors keep
+// the shard index in their top shardBits bits.
+const maxShards = 1 << shardBits
+
+// shardBits is the number of cursor bits reserved for the shard index.
+const shardBits = 16
+
+// seqBits is the number of cursor bits left for a shard's own cursor.
+const seqBits = 64 - shardBits
+
+// ShardedCache is a cache partitioned into independent LRUCache shards by
+// a hash of the key. Each shard has its own lock, list and eviction
+// policy, so operations on different shards run in parallel instead of
+// serializing on a single mutex. Capacity and eviction are per shard:
+// each shard evicts its own entries once it holds its share of the
+// capacity.
+type ShardedCache[K comparable, V any] struct {
+	shards      []*LRUCache[K, V]
+	hash        func(key K) uint64                // picks the shard of a key
+	replicas    atomic.Pointer[map[K]Entry[K, V]] // hot keys served by Get without locking
+	replicating atomic.Int32                      // ReplicateHotKeys calls in progress
+	hotMu       sync.Mutex                        // serializes changes to replicas
+	hotHits     atomic.Uint64                     // Get hits served from replicas
+}
+
+// NewShardedCache creates a cache of the given number of shards that
+// together hold capacity entries, with expireSec and opts applied to
+// every shard as in NewLRUCache. A WithMaxBytes budget is split evenly
+// between the shards, and WithShardHash sets how keys are spread over
+// them. It panics unless 1 <= shards <= 65536.
+func NewShardedCache[K comparable, V any](shards, capacity, expireSec int, opts ...Option) *ShardedCache[K, V] {
+	if shards < 1 || shards > maxShards {
+		panic(fmt.Sprintf("lru: %d shards not in [1, %d]", shards, maxShards))
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxBytes > 0 {
+		opts = append(opts[:len(opts):len(opts)], WithMaxBytes(max(o.maxBytes/int64(shards), 1)))
+	}
+
+	sc := &ShardedCache[K, V]{
+		shards: make([]*LRUCache[K, V], shards),
+	}
+	if o.shardHash != nil {
+		hash, ok := o.shardHash.(func(K) uint64)
+		if !ok {
+			panic("lru: WithShardHash key type does not match the cache")
+		}
+		sc.hash = hash
+	} else {
+		seed := maphash.MakeSeed()
+		sc.hash = func(key K) uint64 { return maphash.Comparable(seed, key) }
+	}
+	for i := range sc.shards {
+		sc.shards[i] = NewLRUCache[K, V](shareOf(capacity, shards, i), expireSec, opts...)
+	}
+	return sc
+}
+
+// WithShardHash makes a ShardedCache pick the shard of a key from
+// hash(key), in place of a randomly seeded hash/maphash. It lets keys with
+// skewed or adversarial patterns be spread evenly with a hash suited to
+// them, such as xxhash.Sum64String or an FNV-1a hash from hash/fnv, or
+// makes shard placement stable across processes. The key type must match
+// the cache's, or NewShardedCache panics. NewLRUCache ignores it.
+func WithShardHash[K comparable](hash func(key K) uint64) Option {
+	return func(o *options) {
+		o.shardHash = hash
+	}
+}
+
+// shareOf returns the part of total given to shard i of n, spreading the
+// remainder over the first shards.
+func shareOf(total, n, i int) int {
+	share := total / n
+	if i < total%n {
+		share++
+	}
+	return share
+}
+
+// Shard returns the shard that holds key, for use with the package-level
+// functions such as Incr that take an *LRUCache.
+func (sc *ShardedCache[K, V]) Shard(key K) *LRUCache[K, V] {
+	return sc.shards[sc.index(key)]
+}
+
+// index returns the index of the shard that holds key.
+func (sc *ShardedCache[K, V]) index(key K) int {
+	return int(sc.hash(key) % uint64(len(sc.shards)))
+}
+
+// Get behaves like LRUCache.Get, except that keys replicated by
+// ReplicateHotKeys are served without taking any shard's lock.
+func (sc *ShardedCache[K, V]) Get(key K) (V, bool) {
+	if m := sc.replicas.Load(); m != nil {
+		if entry, found := (*m)[key]; found && (entry.ExpireAt.IsZero() || time.Now().Before(entry.ExpireAt)) {
+			sc.hotHits.Add(1)
+			return entry.Value, true
+		}
+	}
+	return sc.Shard(key).Get(key)
+}
+
+// GetWithInfo behaves like LRUCache.GetWithInfo.
+func (sc *ShardedCache[K, V]) GetWithInfo(key K) (ItemInfo[V], bool) {
+	return sc.Shard(key).GetWithInfo(key)
+}
+
+// Lookup behaves like LRUCache.Lookup.
+func (sc *ShardedCache[K, V]) Lookup(key K) (V, LookupResult) {
+	return sc.Shard(key).Lookup(key)
+}
+
+// Peek behaves like LRUCache.Peek.
+func (sc *ShardedCache[K, V]) Peek(key K) (V, bool) {
+	return sc.Shard(key).Peek(key)
+}
+
+// Contains behaves like LRUCache.Contains.
+func (sc *ShardedCache[K, V]) Contains(key K) bool {
+	return sc.Shard(key).Contains(key)
+}
+
+// TTL behaves like LRUCache.TTL.
+func (sc *ShardedCache[K, V]) TTL(key K) (time.Duration, bool) {
+	return sc.Shard(key).TTL(key)
+}
+
+// GetVersion behaves like LRUCache.GetVersion. Versions are only
+// comparable between writes to the same key.
+func (sc *ShardedCache[K, V]) GetVersion(key K) (V, uint64, bool) {
+	return sc.Shard(key).GetVersion(key)
+}
+
+// CompareAndSwap behaves like LRUCache.CompareAndSwap.
+func (sc *ShardedCache[K, V]) CompareAndSwap(key K, version uint64, value V) (uint64, bool) {
+	defer sc.written(key)
+	return sc.Shard(key).CompareAndSwap(key, version, value)
+}
+
+// Set behaves like LRUCache.Set.
+func (sc *ShardedCache[K, V]) Set(key K, value V) {
+	defer sc.written(key)
+	sc.Shard(key).Set(key, value)
+}
+
+// SetWithTTL behaves like LRUCache.SetWithTTL.
+func (sc *ShardedCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	defer sc.written(key)
+	sc.Shard(key).SetWithTTL(key, value, ttl)
+}
+
+// SetWithDeadline behaves like LRUCache.SetWithDeadline.
+func (sc *ShardedCache[K, V]) SetWithDeadline(key K, value V, deadline time.Time) {
+	defer sc.written(key)
+	sc.Shard(key).SetWithDeadline(key, value, deadline)
+}
+
+// SetWithPriority behaves like LRUCache.SetWithPriority. Priorities order
+// evictions within a shard.
+func (sc *ShardedCache[K, V]) SetWithPriority(key K, value V, priority Priority) {
+	defer sc.written(key)
+	sc.Shard(key).SetWithPriority(key, value, priority)
+}
+
+// SetPriority behaves like LRUCache.SetPriority.
+func (sc *ShardedCache[K, V]) SetPriority(key K, priority Priority) bool {
+	return sc.Shard(key).SetPriority(key, priority)
+}
+
+// SetNegative behaves like LRUCache.SetNegative.
+func (sc *ShardedCache[K, V]) SetNegative(key K, ttl time.Duration) {
+	defer sc.written(key)
+	sc.Shard(key).SetNegative(key, ttl)
+}
+
+// SetIfAbsent behaves like LRUCache.SetIfAbsent.
+func (sc *ShardedCache[K, V]) SetIfAbsent(key K, value V, ttl time.Duration) bool {
+	defer sc.written(key)
+	return sc.Shard(key).SetIfAbsent(key, value, ttl)
+}
+
+// SetIfPresent behaves like LRUCache.SetIfPresent.
+func (sc *ShardedCache[K, V]) SetIfPresent(key K, value V, ttl time.Duration) bool {
+	defer sc.written(key)
+	return sc.Shard(key).SetIfPresent(key, value, ttl)
+}
+
+// GetOrSet behaves like LRUCache.GetOrSet.
+func (sc *ShardedCache[K, V]) GetOrSet(key K, value V) (actual V, loaded bool) {
+	defer sc.written(key)
+	return sc.Shard(key).GetOrSet(key, value)
+}
+
+// GetOrLoad behaves like LRUCache.GetOrLoad.
+func (sc *ShardedCache[K, V]) GetOrLoad(key K, loader func(key K) (V, error)) (V, error) {
+	defer sc.written(key)
+	return sc.Shard(key).GetOrLoad(key, loader)
+}
+
+// GetOrLoadStale behaves like LRUCache.GetOrLoadStale.
+func (sc *ShardedCache[K, V]) GetOrLoadStale(key K, loader func(key K) (V, error)) (value V, stale bool, err error) {
+	defer sc.written(key)
+	return sc.Shard(key).GetOrLoadStale(key, loader)
+}
+
+// Delete behaves like LRUCache.Delete.
+func (sc *ShardedCache[K, V]) Delete(key K) bool {
+	defer sc.written(key)
+	return sc.Shard(key).Delete(key)
+}
+
+// GetDel behaves like LRUCache.GetDel.
+func (sc *ShardedCache[K, V]) GetDel(key K) (V, bool) {
+	defer sc.written(key)
+	return sc.Shard(key).GetDel(key)
+}
+
+// Touch behaves like LRUCache.Touch.
+func (sc *ShardedCache[K, V]) Touch(key K) bool {
+	defer sc.written(key)
+	return sc.Shard(key).Touch(key)
+}
+
+// Expire behaves like LRUCache.Expire.
+func (sc *ShardedCache[K, V]) Expire(key K, ttl time.Duration) bool {
+	defer sc.written(key)
+	return sc.Shard(key).Expire(key, ttl)
+}
+
+// Persist behaves like LRUCache.Persist.
+func (sc *ShardedCache[K, V]) Persist(key K) bool {
+	defer sc.written(key)
+	return sc.Shard(key).Persist(key)
+}
+
+// Pin behaves like LRUCache.Pin.
+func (sc *ShardedCache[K, V]) Pin(key K) bool {
+	return sc.Shard(key).Pin(key)
+}
+
+// Unpin behaves like LRUCache.Unpin.
+func (sc *ShardedCache[K, V]) Unpin(key K) bool {
+	return sc.Shard(key).Unpin(key)
+}
+
+// MGet behaves like LRUCache.MGet, taking each shard's lock once.
+func (sc *ShardedCache[K, V]) MGet(keys []K) map[K]V {
+	byShard := make(map[int][]K)
+	for _, key := range keys {
+		i := sc.index(key)
+		byShard[i] = append(byShard[i], key)
+	}
+	values := make(map[K]V, len(keys))
+	for i, keys := range byShard {
+		for key, v := range sc.shards[i].MGet(keys) {
+			values[key] = v
+		}
+	}
+	return values
+}
+
+// MSet behaves like LRUCache.MSet, taking each shard's lock once. The
+// writes to different shards are not atomic with respect to each other.
+func (sc *ShardedCache[K, V]) MSet(items map[K]V) {
+	byShard := make(map[int]map[K]V)
+	for key, value := range items {
+		i := sc.index(key)
+		if byShard[i] == nil {
+			byShard[i] = make(map[K]V)
+		}
+		byShard[i][key] = value
+	}
+	for i, items := range byShard {
+		sc.shards[i].MSet(items)
+	}
+	for key := range items {
+		sc.written(key)
+	}
+}
+
+// Len returns the number of live entries across all shards.
+func (sc *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, shard := range sc.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Weight returns the total weight of the entries across all shards.
+func (sc *ShardedCache[K, V]) Weight() int64 {
+	var w int64
+	for _, shard := range sc.shards {
+		w += shard.Weight()
+	}
+	return w
+}
+
+// Bytes returns the estimated memory held by the entries across all
+// shards.
+func (sc *ShardedCache[K, V]) Bytes() int64 {
+	var n int64
+	for _, shard := range sc.shards {
+		n += shard.Bytes()
+	}
+	return n
+}
+
+// Cap returns the total capacity of the shards.
+func (sc *ShardedCache[K, V]) Cap() int {
+	n := 0
+	for _, shard := range sc.shards {
+		n += shard.Cap()
+	}
+	return n
+}
+
+// Resize spreads a new total capacity over the shards as
+// NewShardedCache does and returns the number of entries evicted.
+func (sc *ShardedCache[K, V]) Resize(capacity int) int {
+	evicted := 0
+	for i, shard := range sc.shards {
+		evicted += shard.Resize(shareOf(capacity, len(sc.shards), i))
+	}
+	return evicted
+}
+
+// Keys returns a snapshot of the non-expired keys of every shard in turn,
+// each shard's keys ordered as by LRUCache.Keys. The snapshot is not
+// atomic across shards.
+func (sc *ShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range sc.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+// Entries returns a snapshot of the non-expired entries in the same order
+// as Keys.
+func (sc *ShardedCache[K, V]) Entries() []Entry[K, V] {
+	var entries []Entry[K, V]
+	for _, shard := range sc.shards {
+		entries = append(entries, shard.Entries()...)
+	}
+	return entries
+}
+
+// Range calls f for each entry in the same order as Keys until f returns
+// false.
+func (sc *ShardedCache[K, V]) Range(f func(key K, value V, expireAt time.Time) bool) {
+	for _, entry := range sc.Entries() {
+		if !f(entry.Key, entry.Value, entry.ExpireAt) {
+			return
+		}
+	}
+}
+
+// Scan behaves like LRUCache.Scan, visiting the shards in turn. The top
+// bits of the cursor hold the index of the shard being scanned.
+func (sc *ShardedCache[K, V]) Scan(cursor uint64, count int) ([]K, uint64) {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	i, seq := int(cursor>>seqBits), cursor&(1<<seqBits-1)
+	keys := make([]K, 0, count)
+	for i < len(sc.shards) && len(keys) < count {
+		page, next := sc.shards[i].Scan(seq, count-len(keys))
+		keys = append(keys, page...)
+		if next != 0 {
+			return keys, uint64(i)<<seqBits | next
+		}
+		i, seq = i+1, 0
+	}
+	if i >= len(sc.shards) {
+		return keys, 0
+	}
+	return keys, uint64(i) << seqBits
+}
+
+// Flush waits until the writes queued on every shard by Set and
+// SetWithTTL have been applied.
+func (sc *ShardedCache[K, V]) Flush() {
+	for _, shard := range sc.shards {
+		shard.Flush()
+	}
+}
+
+// Clear removes all entries from every shard and drops the replicas.
+func (sc *ShardedCache[K, V]) Clear() {
+	for _, shard := range sc.shards {
+		shard.Clear()
+	}
+	sc.dropReplicas()
+}
+
+// Close closes every shard. Closing an already closed cache returns
+// ErrClosed.
+func (sc *ShardedCache[K, V]) Close() error {
+	var err error
+	for _, shard := range sc.shards {
+		if e := shard.Close(); e != nil {
+			err = e
+		}
+	}
+	sc.dropReplicas()
+	return err
+}
+
+// Stats returns the sum of the shards' counters, with Get hits served
+// from replicas counted as hits.
+func (sc *ShardedCache[K, V]) Stats() Stats {
+	total := Stats{Hits: sc.hotHits.Load()}
+	for _, shard := range sc.shards {
+		s := shard.Stats()
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Sets += s.Sets
+		total.Evictions += s.Evictions
+		total.Expirations += s.Expirations
+	}
+	return total
+}
+
+// HitRates returns the hit rate across all shards over the last 1, 5 and
+// 15 minutes.
+func (sc *ShardedCache[K, V]) HitRates() HitRates {
+	var hits, misses [3]uint64
+	for _, shard := range sc.shards {
+		h, m := shard.recentCounts()
+		for j := range hits {
+			hits[j] += h[j]
+			misses[j] += m[j]
+		}
+	}
+	return HitRates{
+		OneMinute:      hitRate(hits[0], misses[0]),
+		FiveMinutes:    hitRate(hits[1], misses[1]),
+		FifteenMinutes: hitRate(hits[2], misses[2]),
+	}
+}
+
+// ResetStats zeroes the counters and hit-rate windows of every shard.
+func (sc *ShardedCache[K, V]) ResetStats() {
+	for _, shard := range sc.shards {
+		shard.ResetStats()
+	}
+	sc.hotHits.Store(0)
+}
+
+// HotKeys returns the most read keys across all shards, hottest first, if
+// the shards were created with WithHotKeys. As many keys are returned as
+// each shard tracks.
+func (sc *ShardedCache[K, V]) HotKeys() []HotKey[K] {
+	var keys []HotKey[K]
+	k := 0
+	for _, shard := range sc.shards {
+		hot := shard.HotKeys()
+		keys = append(keys, hot...)
+		k = max(k, len(hot))
+	}
+	sortHotKeys(keys)
+	return keys[:k]
+}
+
+// ReplicateHotKeys snapshots the values of the keys reported by HotKeys,
+// replacing the previous snapshot, and returns the number of keys taken.
+// Get then serves these keys from the snapshot without locking, so that a
+// few very hot keys do not saturate the shards holding them; call it
+// periodically to follow the hot set. Writes through the ShardedCache drop
+// the written key from the snapshot, but writes through a shard returned
+// by Shard do not, and a replicated key that is evicted is still served
+// until its expiry or the next call.
+func (sc *ShardedCache[K, V]) ReplicateHotKeys() int {
+	sc.replicating.Add(1)
+	defer sc.replicating.Add(-1)
+	sc.hotMu.Lock()
+	defer sc.hotMu.Unlock()
+
+	replicas := make(map[K]Entry[K, V])
+	for _, hot := range sc.HotKeys() {
+		if entry, found := sc.Shard(hot.Key).peekEntry(hot.Key); found {
+			replicas[hot.Key] = entry
+		}
+	}
+	sc.replicas.Store(&replicas)
+	return len(replicas)
+}
+
+// written drops key from the replicas once it has been written. Writers
+// also wait for ReplicateHotKeys calls in progress, which may have read
+// the key before the write.
+func (sc *ShardedCache[K, V]) written(key K) {
+	if sc.replicating.Load() == 0 && !sc.replicated(key) {
+		return
+	}
+
+	sc.hotMu.Lock()
+	defer sc.hotMu.Unlock()
+
+	if !sc.replicated(key) {
+		return
+	}
+	replicas := make(map[K]Entry[K, V], len(*sc.replicas.Load()))
+	for k, entry := range *sc.replicas.Load() {
+		if k != key {
+			replicas[k] = entry
+		}
+	}
+	sc.replicas.Store(&replicas)
+}
+
+// replicated reports whether key is in the replicas.
+func (sc *ShardedCache[K, V]) replicated(key K) bool {
+	m := sc.replicas.Load()
+	if m == nil {
+		return false
+	}
+	_, found := (*m)[key]
+	return found
+}
+
+// dropReplicas empties the replicas.
+func (sc *ShardedCache[K, V]) dropReplicas() {
+	sc.hotMu.Lock()
+	defer sc.hotMu.Unlock()
+
+	sc.replicas.Store(nil)
+}
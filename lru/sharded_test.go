@@ -0,0 +1,95 @@
+package lru
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestShardedCacheRouting(t *testing.T) {
+	const shards = 4
+	sc := NewShardedCache[int, int](shards, 40, 0, WithShardHash(func(key int) uint64 { return uint64(key) }))
+	for k := range 20 {
+		sc.Set(k, k)
+	}
+	for k := range 20 {
+		if sc.Shard(k) != sc.shards[k%shards] {
+			t.Errorf("key %d routed to the wrong shard", k)
+		}
+		for i, shard := range sc.shards {
+			if shard.Contains(k) != (i == k%shards) {
+				t.Errorf("shard %d holds key %d: %v", i, k, shard.Contains(k))
+			}
+		}
+	}
+	if sc.Len() != 20 {
+		t.Errorf("Len() = %d, want 20", sc.Len())
+	}
+	if s := sc.Stats(); s.Sets != 20 {
+		t.Errorf("Stats().Sets = %d, want 20", s.Sets)
+	}
+}
+
+func TestShardedCacheConcurrent(t *testing.T) {
+	sc := NewShardedCache[int, int](8, 1000, 0)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				k := g*500 + i
+				sc.Set(k%1000, k)
+				sc.Get(k % 1000)
+				sc.Delete((k + 7) % 1000)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := sc.Len(); n > 1000 {
+		t.Errorf("Len() = %d, more than the capacity", n)
+	}
+}
+
+func TestReplicateHotKeysDropsWrittenKey(t *testing.T) {
+	sc := NewShardedCache[string, int](4, 100, 0, WithHotKeys(2))
+	sc.Set("hot", 0)
+	for range 10 {
+		sc.Get("hot")
+	}
+	if n := sc.ReplicateHotKeys(); n != 1 {
+		t.Fatalf("ReplicateHotKeys() = %d, want 1", n)
+	}
+	hits := sc.Stats().Hits
+	if v, _ := sc.Get("hot"); v != 0 || !sc.replicated("hot") || sc.Stats().Hits != hits+1 {
+		t.Fatalf("Get(hot) = %d not served from the replica", v)
+	}
+
+	// Replicate and read concurrently with the writes; once a write has
+	// returned, no read may see an older value
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				sc.ReplicateHotKeys()
+				sc.Get("hot")
+			}
+		}()
+	}
+	for i := 1; i <= 1000; i++ {
+		sc.Set("hot", i)
+		if v, _ := sc.Get("hot"); v != i {
+			t.Errorf("Get after Set(hot, %d) = %d", i, v)
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,31 @@
+package lru
+
+import "reflect"
+
+// entryOverhead approximates the memory a cache entry costs beyond its key
+// and value: the CacheItem, its map slot and its list elements.
+const entryOverhead = 192
+
+// entrySize estimates the memory held by an entry, counting the contents
+// of string, slice and map keys and values rather than just their headers.
+func entrySize[K comparable, V any](key K, value V) int64 {
+	return entryOverhead + sizeOf(reflect.ValueOf(key)) + sizeOf(reflect.ValueOf(value))
+}
+
+// sizeOf estimates the memory held by v. Pointers and interfaces are not
+// followed.
+func sizeOf(v reflect.Value) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+	size := int64(v.Type().Size())
+	switch v.Kind() {
+	case reflect.String:
+		size += int64(v.Len())
+	case reflect.Slice:
+		size += int64(v.Len()) * int64(v.Type().Elem().Size())
+	case reflect.Map:
+		size += int64(v.Len()) * int64(v.Type().Key().Size()+v.Type().Elem().Size())
+	}
+	return size
+}
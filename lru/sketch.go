@@ -0,0 +1,90 @@
+package lru
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// sketchDepth is the number of rows of a countMinSketch.
+const sketchDepth = 4
+
+// sketchMaxCount is the value at which the 4-bit counters of the sketch
+// used by TinyLFU saturate.
+const sketchMaxCount = 15
+
+// counter is the type of the counters of a countMinSketch.
+type counter interface {
+	uint8 | uint32
+}
+
+// countMinSketch estimates how often keys were seen using a small, fixed
+// amount of memory. Estimates never undercount, save that counters
+// saturate at limit. Every counter is halved once resetAfter increments
+// have been recorded, so the sketch tracks recent rather than all-time
+// popularity.
+type countMinSketch[K comparable, C counter] struct {
+	seed       maphash.Seed
+	rows       [sketchDepth][]C
+	limit      C
+	mask       uint64
+	additions  int
+	resetAfter int
+}
+
+// newCountMinSketch returns a sketch sized for a cache of the given
+// capacity whose counters saturate at limit.
+func newCountMinSketch[K comparable, C counter](capacity int, limit C) *countMinSketch[K, C] {
+	width := 1 << bits.Len(uint(max(capacity, 16)-1))
+	s := &countMinSketch[K, C]{
+		seed:       maphash.MakeSeed(),
+		limit:      limit,
+		mask:       uint64(width - 1),
+		resetAfter: 10 * max(capacity, 1),
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]C, width)
+	}
+	return s
+}
+
+// increment records one occurrence of key.
+func (s *countMinSketch[K, C]) increment(key K) {
+	h1, h2 := s.hash(key)
+	for i := range s.rows {
+		c := &s.rows[i][(h1+uint64(i)*h2)&s.mask]
+		if *c < s.limit {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAfter {
+		s.reset()
+	}
+}
+
+// estimate returns the approximate number of recent occurrences of key.
+func (s *countMinSketch[K, C]) estimate(key K) C {
+	h1, h2 := s.hash(key)
+	n := s.limit
+	for i := range s.rows {
+		n = min(n, s.rows[i][(h1+uint64(i)*h2)&s.mask])
+	}
+	return n
+}
+
+// reset halves every counter.
+func (s *countMinSketch[K, C]) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// hash returns the two hashes from which the row indexes of key are
+// derived.
+func (s *countMinSketch[K, C]) hash(key K) (uint64, uint64) {
+	h := maphash.Comparable(s.seed, key)
+	return h, h>>32 | 1
+}
@@ -0,0 +1,131 @@
+package lru
+
+import "fmt"
+
+// defaultProtectedRatio is the share of the capacity NewSLRUPolicy gives
+// to the protected segment.
+const defaultProtectedRatio = 0.8
+
+// slruPolicy implements segmented LRU. New keys enter the probation
+// segment and are promoted to the protected segment when read again; keys
+// pushed out of a full protected segment drop back to probation. Victims
+// are taken from probation first.
+type slruPolicy[K comparable] struct {
+	ratio     float64 // share of the capacity for the protected segment
+	maxProt   int     // size of the protected segment
+	probation *linkedList[K]
+	protected *linkedList[K]
+	entries   map[K]*slruEntry[K]
+}
+
+// slruEntry records which segment a key is in.
+type slruEntry[K comparable] struct {
+	elem      *node[K]
+	protected bool
+}
+
+// NewSLRUPolicy returns a segmented LRU eviction policy that gives 80% of
+// the capacity to the protected segment.
+func NewSLRUPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	return newSLRUPolicy[K](capacity, defaultProtectedRatio)
+}
+
+// NewSLRUPolicyWithRatio returns a segmented LRU policy constructor, for
+// use with WithPolicy, that gives the share protectedRatio of the capacity
+// to the protected segment. It panics unless 0 < protectedRatio < 1.
+func NewSLRUPolicyWithRatio[K comparable](protectedRatio float64) func(capacity int) EvictionPolicy[K] {
+	if protectedRatio <= 0 || protectedRatio >= 1 {
+		panic(fmt.Sprintf("lru: SLRU protected ratio %v not between 0 and 1", protectedRatio))
+	}
+	return func(capacity int) EvictionPolicy[K] {
+		return newSLRUPolicy[K](capacity, protectedRatio)
+	}
+}
+
+func newSLRUPolicy[K comparable](capacity int, ratio float64) *slruPolicy[K] {
+	p := &slruPolicy[K]{
+		ratio:     ratio,
+		probation: newLinkedList[K](),
+		protected: newLinkedList[K](),
+		entries:   make(map[K]*slruEntry[K]),
+	}
+	p.resize(capacity)
+	return p
+}
+
+func (p *slruPolicy[K]) OnGet(key K) {
+	if e, found := p.entries[key]; found {
+		p.promote(key, e)
+	}
+}
+
+func (p *slruPolicy[K]) OnSet(key K) {
+	if e, found := p.entries[key]; found {
+		p.promote(key, e)
+		return
+	}
+	p.entries[key] = &slruEntry[K]{elem: p.probation.PushFront(key)}
+}
+
+func (p *slruPolicy[K]) OnRemove(key K) {
+	if e, found := p.entries[key]; found {
+		p.segment(e).Remove(e.elem)
+		delete(p.entries, key)
+	}
+}
+
+func (p *slruPolicy[K]) Victim() (K, bool) {
+	elem := p.probation.Back()
+	if elem == nil {
+		elem = p.protected.Back()
+	}
+	if elem == nil {
+		var zero K
+		return zero, false
+	}
+	return elem.Value, true
+}
+
+func (p *slruPolicy[K]) walk(f func(key K)) {
+	for _, l := range []*linkedList[K]{p.protected, p.probation} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			f(elem.Value)
+		}
+	}
+}
+
+func (p *slruPolicy[K]) resize(capacity int) {
+	p.maxProt = max(int(float64(capacity)*p.ratio), 1)
+	p.demote()
+}
+
+// segment returns the list holding e.
+func (p *slruPolicy[K]) segment(e *slruEntry[K]) *linkedList[K] {
+	if e.protected {
+		return p.protected
+	}
+	return p.probation
+}
+
+// promote moves key to the front of the protected segment.
+func (p *slruPolicy[K]) promote(key K, e *slruEntry[K]) {
+	if e.protected {
+		p.protected.MoveToFront(e.elem)
+		return
+	}
+	p.probation.Remove(e.elem)
+	e.elem = p.protected.PushFront(key)
+	e.protected = true
+	p.demote()
+}
+
+// demote moves keys from the back of the protected segment to the front
+// of probation until the protected segment fits maxProt.
+func (p *slruPolicy[K]) demote() {
+	for p.protected.Len() > p.maxProt {
+		key := p.protected.Remove(p.protected.Back())
+		e := p.entries[key]
+		e.elem = p.probation.PushFront(key)
+		e.protected = false
+	}
+}
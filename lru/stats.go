@@ -0,0 +1,173 @@
+package lru
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the cache's lifetime counters.
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Sets        uint64 `json:"sets"`
+	Evictions   uint64 `json:"evictions"`   // entries removed to make room
+	Expirations uint64 `json:"expirations"` // entries removed after their TTL
+}
+
+// HitRate returns the fraction of lookups that were hits, or 0 if there
+// have been no lookups.
+func (s Stats) HitRate() float64 {
+	return hitRate(s.Hits, s.Misses)
+}
+
+// HitRates holds the hit rate over recent rolling windows.
+type HitRates struct {
+	OneMinute      float64 `json:"1m"`
+	FiveMinutes    float64 `json:"5m"`
+	FifteenMinutes float64 `json:"15m"`
+}
+
+// counters holds the live counters behind Stats. They are atomic so that
+// Stats can be read without taking the cache lock.
+type counters struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	sets        atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// windowMinutes is the span covered by the rolling hit-rate window.
+const windowMinutes = 15
+
+// window counts hits and misses in one bucket per minute over the last
+// windowMinutes minutes. Its buckets are atomic so that reads holding only
+// the read lock can record into it; a lookup racing with the rollover of
+// its bucket to a new minute may go uncounted.
+type window struct {
+	buckets [windowMinutes]bucket
+}
+
+// bucket holds the hits and misses recorded during one minute.
+type bucket struct {
+	minute atomic.Int64 // minutes since the Unix epoch
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// record counts a lookup at the given time.
+func (w *window) record(now time.Time, hit bool) {
+	minute := now.Unix() / 60
+	b := &w.buckets[minute%windowMinutes]
+	if old := b.minute.Load(); old != minute && b.minute.CompareAndSwap(old, minute) {
+		b.hits.Store(0)
+		b.misses.Store(0)
+	}
+	if hit {
+		b.hits.Add(1)
+	} else {
+		b.misses.Add(1)
+	}
+}
+
+// rate returns the hit rate over the last n minutes, including the
+// current one.
+func (w *window) rate(now time.Time, n int) float64 {
+	return hitRate(w.counts(now, n))
+}
+
+// counts returns the hits and misses over the last n minutes, including
+// the current one.
+func (w *window) counts(now time.Time, n int) (hits, misses uint64) {
+	minute := now.Unix() / 60
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if m := b.minute.Load(); m > minute-int64(n) && m <= minute {
+			hits += b.hits.Load()
+			misses += b.misses.Load()
+		}
+	}
+	return hits, misses
+}
+
+// reset zeroes every bucket.
+func (w *window) reset() {
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		b.minute.Store(0)
+		b.hits.Store(0)
+		b.misses.Store(0)
+	}
+}
+
+// hitRate returns hits over total lookups, or 0 if there were none.
+func hitRate(hits, misses uint64) float64 {
+	total := hits + misses
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// recordHit counts a hit.
+func (lru *LRUCache[K, V]) recordHit(now time.Time) {
+	lru.stats.hits.Add(1)
+	if !lru.noWindow {
+		lru.window.record(now, true)
+	}
+}
+
+// recordMiss counts a miss.
+func (lru *LRUCache[K, V]) recordMiss(now time.Time) {
+	lru.stats.misses.Add(1)
+	if !lru.noWindow {
+		lru.window.record(now, false)
+	}
+}
+
+// Stats returns a snapshot of the cache's counters. Get, Lookup and the
+// other promoting reads count as hits or misses; Peek, Contains and TTL do
+// not.
+func (lru *LRUCache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        lru.stats.hits.Load(),
+		Misses:      lru.stats.misses.Load(),
+		Sets:        lru.stats.sets.Load(),
+		Evictions:   lru.stats.evictions.Load(),
+		Expirations: lru.stats.expirations.Load(),
+	}
+}
+
+// HitRates returns the hit rate over the last 1, 5 and 15 minutes, so
+// recent behavior can be told apart from lifetime aggregates.
+func (lru *LRUCache[K, V]) HitRates() HitRates {
+	now := time.Now()
+	return HitRates{
+		OneMinute:      lru.window.rate(now, 1),
+		FiveMinutes:    lru.window.rate(now, 5),
+		FifteenMinutes: lru.window.rate(now, 15),
+	}
+}
+
+// recentCounts returns the hits and misses over the last 1, 5 and 15
+// minutes, for aggregating hit rates across shards.
+func (lru *LRUCache[K, V]) recentCounts() (hits, misses [3]uint64) {
+	now := time.Now()
+	for i, n := range [3]int{1, 5, 15} {
+		hits[i], misses[i] = lru.window.counts(now, n)
+	}
+	return hits, misses
+}
+
+// ResetStats zeroes all counters and the rolling hit-rate window.
+func (lru *LRUCache[K, V]) ResetStats() {
+	lru.lock()
+	defer lru.unlock()
+
+	lru.stats.hits.Store(0)
+	lru.stats.misses.Store(0)
+	lru.stats.sets.Store(0)
+	lru.stats.evictions.Store(0)
+	lru.stats.expirations.Store(0)
+	lru.window.reset()
+}
@@ -0,0 +1,84 @@
+package lru
+
+// tinyLFUPolicy implements W-TinyLFU. New keys enter a small LRU window.
+// A key leaving the window is only admitted to the segmented LRU main
+// area if a count-min sketch estimates it to be more popular than the
+// main area's victim; otherwise the key itself is evicted. Keys that are
+// seen once therefore never push valuable entries out of the cache.
+type tinyLFUPolicy[K comparable] struct {
+	window    *lruPolicy[K]
+	main      *slruPolicy[K]
+	sketch    *countMinSketch[K, uint8]
+	maxWindow int // size of the window
+	maxMain   int // size of the main area
+}
+
+// NewTinyLFUPolicy returns a W-TinyLFU eviction policy, which gives 1% of
+// the capacity to the admission window and rejects low-frequency keys
+// instead of evicting frequently used ones.
+func NewTinyLFUPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	p := &tinyLFUPolicy[K]{
+		window: NewLRUPolicy[K](capacity).(*lruPolicy[K]),
+		main:   newSLRUPolicy[K](capacity, defaultProtectedRatio),
+		sketch: newCountMinSketch[K, uint8](capacity, sketchMaxCount),
+	}
+	p.resize(capacity)
+	return p
+}
+
+func (p *tinyLFUPolicy[K]) OnGet(key K) {
+	p.sketch.increment(key)
+	p.window.OnGet(key)
+	p.main.OnGet(key)
+}
+
+func (p *tinyLFUPolicy[K]) OnSet(key K) {
+	p.sketch.increment(key)
+	if _, found := p.main.entries[key]; found {
+		p.main.OnSet(key)
+		return
+	}
+	p.window.OnSet(key)
+}
+
+func (p *tinyLFUPolicy[K]) OnRemove(key K) {
+	p.window.OnRemove(key)
+	p.main.OnRemove(key)
+}
+
+func (p *tinyLFUPolicy[K]) Victim() (K, bool) {
+	for p.window.list.Len() > p.maxWindow {
+		candidate := p.window.list.Back().Value
+		if len(p.main.entries) < p.maxMain {
+			p.admit(candidate)
+			continue
+		}
+		victim, ok := p.main.Victim()
+		if !ok || p.sketch.estimate(candidate) <= p.sketch.estimate(victim) {
+			return candidate, true
+		}
+		p.admit(candidate)
+		return victim, true
+	}
+	if key, ok := p.main.Victim(); ok {
+		return key, true
+	}
+	return p.window.Victim()
+}
+
+func (p *tinyLFUPolicy[K]) walk(f func(key K)) {
+	p.main.walk(f)
+	p.window.walk(f)
+}
+
+func (p *tinyLFUPolicy[K]) resize(capacity int) {
+	p.maxWindow = max(capacity/100, 1)
+	p.maxMain = capacity - p.maxWindow
+	p.main.resize(p.maxMain)
+}
+
+// admit moves key from the window to the main area.
+func (p *tinyLFUPolicy[K]) admit(key K) {
+	p.window.OnRemove(key)
+	p.main.OnSet(key)
+}
@@ -0,0 +1,120 @@
+package lru
+
+// Queues of a twoQPolicy.
+const (
+	twoQIn  = iota // probationary FIFO of keys seen once
+	twoQOut        // ghosts of keys evicted from twoQIn
+	twoQHot        // protected LRU of keys seen again
+)
+
+// twoQPolicy implements the full 2Q algorithm of Johnson and Shasha. New
+// keys enter a probationary FIFO; only keys requested again after leaving
+// it are promoted to the protected LRU, so one-hit wonders never displace
+// hot entries.
+type twoQPolicy[K comparable] struct {
+	kin, kout int // sizes of the probationary and ghost queues
+	queues    [3]*linkedList[K]
+	entries   map[K]*twoQEntry[K]
+	victim    *twoQEntry[K] // last key returned by Victim
+}
+
+// twoQEntry records which queue a key is on.
+type twoQEntry[K comparable] struct {
+	elem  *node[K]
+	queue int
+}
+
+// NewTwoQPolicy returns a 2Q eviction policy. A quarter of the capacity is
+// given to the probationary queue and the ghost queue remembers half as
+// many keys as the cache holds.
+func NewTwoQPolicy[K comparable](capacity int) EvictionPolicy[K] {
+	p := &twoQPolicy[K]{entries: make(map[K]*twoQEntry[K])}
+	for i := range p.queues {
+		p.queues[i] = newLinkedList[K]()
+	}
+	p.resize(capacity)
+	return p
+}
+
+func (p *twoQPolicy[K]) OnGet(key K) {
+	// Hits on the probationary queue are correlated references and do
+	// not reorder it.
+	if e, found := p.entries[key]; found && e.queue == twoQHot {
+		p.queues[twoQHot].MoveToFront(e.elem)
+	}
+}
+
+func (p *twoQPolicy[K]) OnSet(key K) {
+	e, found := p.entries[key]
+	switch {
+	case !found:
+		p.entries[key] = &twoQEntry[K]{elem: p.queues[twoQIn].PushFront(key), queue: twoQIn}
+	case e.queue == twoQOut:
+		p.move(key, e, twoQHot)
+	case e.queue == twoQHot:
+		p.queues[twoQHot].MoveToFront(e.elem)
+	}
+}
+
+func (p *twoQPolicy[K]) OnRemove(key K) {
+	e, found := p.entries[key]
+	if !found || e.queue == twoQOut {
+		return
+	}
+	if e != p.victim || e.queue == twoQHot {
+		p.queues[e.queue].Remove(e.elem)
+		delete(p.entries, key)
+		return
+	}
+
+	// Evicted from the probationary queue: remember it as a ghost.
+	p.victim = nil
+	p.move(key, e, twoQOut)
+	p.trimGhosts()
+}
+
+func (p *twoQPolicy[K]) Victim() (K, bool) {
+	in, hot := p.queues[twoQIn], p.queues[twoQHot]
+	elem := hot.Back()
+	if in.Len() > p.kin || elem == nil {
+		elem = in.Back()
+	}
+	if elem == nil {
+		var zero K
+		return zero, false
+	}
+	key := elem.Value
+	p.victim = p.entries[key]
+	return key, true
+}
+
+func (p *twoQPolicy[K]) walk(f func(key K)) {
+	for _, q := range []*linkedList[K]{p.queues[twoQHot], p.queues[twoQIn]} {
+		for elem := q.Front(); elem != nil; elem = elem.Next() {
+			f(elem.Value)
+		}
+	}
+}
+
+func (p *twoQPolicy[K]) resize(capacity int) {
+	p.kin = max(capacity/4, 1)
+	p.kout = max(capacity/2, 1)
+	p.trimGhosts()
+}
+
+// move puts key at the front of the given queue.
+func (p *twoQPolicy[K]) move(key K, e *twoQEntry[K], queue int) {
+	p.queues[e.queue].Remove(e.elem)
+	e.elem = p.queues[queue].PushFront(key)
+	e.queue = queue
+}
+
+// trimGhosts forgets the oldest ghosts beyond kout.
+func (p *twoQPolicy[K]) trimGhosts() {
+	out := p.queues[twoQOut]
+	for out.Len() > p.kout {
+		elem := out.Back()
+		delete(p.entries, elem.Value)
+		out.Remove(elem)
+	}
+}